@@ -0,0 +1,195 @@
+package mira
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	// the db mira client
+	"terraform-provider-mira/miraclient"
+)
+
+// ======================================================================
+// THE FUNCTION ASSIGNED TO THE DATASOURCE IN provider.go [RETURN SCHEMA]
+// ======================================================================
+
+func dataSourceMiraAddress() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "A data source in the Terraform provider Mira for resolving an address id to its site details, or for finding the address ids in a country or region.",
+
+		// the function in this file assigned to the READ CRUD call
+		ReadContext: dataSourceMiraAddressRead,
+
+		// set the resource fields in the schema
+		Schema: map[string]*schema.Schema{
+			// set one of these three to look up an address, or to search for addresses
+			"address_id": {
+				Description:   "A 7 digit integer that identifies the physical location in the world (a site id for an address)",
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"country", "region"},
+				AtLeastOneOf:  []string{"address_id", "country", "region"},
+			},
+			"country": {
+				Description: "The country to search for addresses in, or the country of the address_id when that is set",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+			},
+			"region": {
+				Description: "The region to search for addresses in, or the region of the address_id when that is set",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+			},
+			// these resources are populated via api responses from mira
+			"city": {
+				Description: "The city of the address_id. Retrieved from MIRA API",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"street": {
+				Description: "The street of the address_id. Retrieved from MIRA API",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"building": {
+				Description: "The building of the address_id. Retrieved from MIRA API",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"site_name": {
+				Description: "The site name of the address_id. Retrieved from MIRA API",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"addresses": {
+				Description: "Every address matching the search, or just the address_id when that is set. Retrieved from MIRA API",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"address_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"country": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"region": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"city": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"street": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"building": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"site_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// =========
+// CRUD READ
+// =========
+
+func dataSourceMiraAddressRead(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	// ----------
+	// GET CLIENT
+	// ----------
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	// ------------------------
+	// GET FIELDS FROM RESOURCE
+	// ------------------------
+
+	addressID := data.Get("address_id").(string)
+	country := data.Get("country").(string)
+	region := data.Get("region").(string)
+
+	// ----------------------------------------
+	// DO MIRA ADDRESS LOOKUP OR SEARCH REQUEST
+	// ----------------------------------------
+
+	var addresses []miraclient.MiraAddressRecord
+
+	if addressID != "" {
+		// resolve the address id to its site details
+		address, err := client.GetMiraAddress(addressID)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		addresses = append(addresses, *address)
+	} else {
+		// reverse search the address ids in a country or region
+		found, err := client.SearchMiraAddresses(&miraclient.MiraAddressSearchQueryInput{
+			Country: country,
+			Region:  region,
+		})
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		addresses = found
+	}
+
+	// -------------------------------
+	// SET RESOURCE WITH API RESPONSES
+	// -------------------------------
+
+	flattened := make([]interface{}, 0, len(addresses))
+	for _, address := range addresses {
+		flattened = append(flattened, map[string]interface{}{
+			"address_id": address.AddressID,
+			"country":    address.Country,
+			"region":     address.Region,
+			"city":       address.City,
+			"street":     address.Street,
+			"building":   address.Building,
+			"site_name":  address.SiteName,
+		})
+	}
+
+	if err := data.Set("addresses", flattened); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// the single address fields are only filled in for a lookup by address id
+	if addressID != "" {
+		for key, value := range flattened[0].(map[string]interface{}) {
+			if err := data.Set(key, value); err != nil {
+				return diag.FromErr(err)
+			}
+		}
+		data.SetId(addressID)
+	} else {
+		data.SetId(country + "/" + region)
+	}
+
+	// ------------------------
+	// RETURN INFO AND WARNINGS
+	// ------------------------
+	return diags
+}
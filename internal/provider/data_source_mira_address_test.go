@@ -0,0 +1,45 @@
+package mira
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceMiraAddress(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceMiraAddressByID,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"data.mira_address.foo", "addresses.#", "1"),
+					resource.TestMatchResourceAttr(
+						"data.mira_address.foo", "country", regexp.MustCompile(".+")),
+				),
+			},
+			{
+				Config: testAccDataSourceMiraAddressByCountry,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr(
+						"data.mira_address.foo", "addresses.0.address_id", regexp.MustCompile("^[0-9]{7}$")),
+				),
+			},
+		},
+	})
+}
+
+const testAccDataSourceMiraAddressByID = `
+data "mira_address" "foo" {
+  address_id = "7654321"
+}
+`
+
+const testAccDataSourceMiraAddressByCountry = `
+data "mira_address" "foo" {
+  country = "DE"
+}
+`
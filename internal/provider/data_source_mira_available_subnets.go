@@ -7,6 +7,7 @@ import (
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	// the db mira client
 	"terraform-provider-mira/miraclient"
@@ -38,7 +39,16 @@ func dataSourceMiraAvailableSubnets() *schema.Resource {
 				Type:        schema.TypeString,
 				Required:    true,
 			},
-			// these two resources are populated via api response from mira
+			"exclude_cidrs": {
+				Description: "Reserved blocks to leave out of the payload, on top of the exclude_cidrs of the provider",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.IsCIDR,
+				},
+			},
+			// these resources are populated via api response from mira
 			"message": {
 				Description: "Mira Response Status Code (OK). Retrieved from MIRA API",
 				Type:        schema.TypeString,
@@ -50,6 +60,12 @@ func dataSourceMiraAvailableSubnets() *schema.Resource {
 				Computed:    true,
 				Elem:        &schema.Schema{Type: schema.TypeString},
 			},
+			"excluded": {
+				Description: "The free subnets MIRA returned that were left out of the payload, as they overlap an excluded CIDR",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
 		},
 	}
 }
@@ -86,6 +102,7 @@ func dataSourceMiraAvailableSubnetsRead(ctx context.Context, data *schema.Resour
 	miraFreeSubnetsQuery := &miraclient.RangeForAvailableMiraSubnetsQueryInput{
 		RequestRange: requestRange,
 		RequestMask:  requestMask,
+		ExcludeCIDRs: expandStringList(data.Get("exclude_cidrs").([]interface{})),
 	}
 
 	// get free subnets from mira range, from api endpoint
@@ -108,6 +125,11 @@ func dataSourceMiraAvailableSubnetsRead(ctx context.Context, data *schema.Resour
 		return diag.FromErr(err)
 	}
 
+	// add the free subnets left out as reserved, so an empty payload can be explained
+	if err := data.Set("excluded", unmarshaledResponseData.Excluded); err != nil {
+		return diag.FromErr(err)
+	}
+
 	// ---------------------------------
 	// SET ID TO UNIX TIME SO ALWAYS NEW
 	// ---------------------------------
@@ -0,0 +1,120 @@
+package mira
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	// the db mira client
+	"terraform-provider-mira/miraclient"
+)
+
+// ======================================================================
+// THE FUNCTION ASSIGNED TO THE DATASOURCE IN provider.go [RETURN SCHEMA]
+// ======================================================================
+
+func dataSourceMiraRangeCatalog() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "A data source in the Terraform provider Mira for reading the named ranges of the provider range_catalog, eg: to pass them on to other modules.",
+
+		// the function in this file assigned to the READ CRUD call
+		ReadContext: dataSourceMiraRangeCatalogRead,
+
+		// set the resource fields in the schema
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Description: "Only return the range with this name, an unknown name is an error",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			// these resources are populated from the provider configuration
+			"ranges": {
+				Description: "The named ranges of the range_catalog, in name order",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"request_range": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"request_mask": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"template": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"address_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// =========
+// CRUD READ
+// =========
+
+func dataSourceMiraRangeCatalogRead(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	// the catalog is in the provider configuration, so nothing is requested from mira
+	names := miraRangeCatalogNames(client)
+	if name := data.Get("name").(string); name != "" {
+		if _, ok := client.RangeCatalog[name]; !ok {
+			return diag.Errorf("the range %q is not in the provider range_catalog", name)
+		}
+		names = []string{name}
+	}
+
+	flattened := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		catalogRange := client.RangeCatalog[name]
+		flattened = append(flattened, map[string]interface{}{
+			"name":          catalogRange.Name,
+			"request_range": catalogRange.RequestRange,
+			"request_mask":  catalogRange.RequestMask,
+			"template":      catalogRange.Template,
+			"address_id":    catalogRange.AddressID,
+		})
+	}
+
+	if err := data.Set("ranges", flattened); err != nil {
+		return diag.FromErr(err)
+	}
+
+	data.SetId("range_catalog/" + data.Get("name").(string))
+
+	// ------------------------
+	// RETURN INFO AND WARNINGS
+	// ------------------------
+	return diags
+}
+
+// func to list the names of the range catalog in order, for stable output and error messages
+func miraRangeCatalogNames(client *miraclient.Client) []string {
+	names := make([]string, 0, len(client.RangeCatalog))
+	for name := range client.RangeCatalog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,50 @@
+package mira
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceMiraRangeCatalog(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceMiraRangeCatalog,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"data.mira_range_catalog.all", "ranges.#", "2"),
+					resource.TestCheckResourceAttr(
+						"data.mira_range_catalog.all", "ranges.0.name", "eu-region3-dev"),
+					resource.TestCheckResourceAttr(
+						"data.mira_range_catalog.prd", "ranges.0.request_range", "10.20.0.0"),
+				),
+			},
+		},
+	})
+}
+
+const testAccDataSourceMiraRangeCatalog = `
+provider "mira" {
+  range_catalog {
+    name          = "eu-region3-prd"
+    request_range = "10.20.0.0"
+    request_mask  = "255.255.0.0"
+    template      = "U25_PRD_GCP"
+    address_id    = "7654321"
+  }
+  range_catalog {
+    name          = "eu-region3-dev"
+    request_range = "10.10.0.0"
+    template      = "U25_DEV_GCP"
+  }
+}
+
+data "mira_range_catalog" "all" {}
+
+data "mira_range_catalog" "prd" {
+  name = "eu-region3-prd"
+}
+`
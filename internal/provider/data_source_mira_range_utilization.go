@@ -0,0 +1,181 @@
+package mira
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	// the db mira client
+	"terraform-provider-mira/miraclient"
+)
+
+// ======================================================================
+// THE FUNCTION ASSIGNED TO THE DATASOURCE IN provider.go [RETURN SCHEMA]
+// ======================================================================
+
+func dataSourceMiraRangeUtilization() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "A data source in the Terraform provider Mira for reporting how much of a range is allocated and how much is still free, per subnet prefix length.",
+
+		// the function in this file assigned to the READ CRUD call
+		ReadContext: dataSourceMiraRangeUtilizationRead,
+
+		// set the resource fields in the schema
+		Schema: map[string]*schema.Schema{
+			// these resources are populated via git and terraform
+			"request_range": {
+				Description: "The Range to report the utilization of",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"range_mask": {
+				Description: "The netmask of the whole range, used to work out how many subnets fit in it",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"prefix_lengths": {
+				Description: "The subnet prefix lengths to report on. Defaults to every prefix length from the size of the range down to a /29",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeInt},
+			},
+			// these resources are populated via api responses from mira
+			"utilization": {
+				Description: "The total, allocated, free and excluded subnet counts for each prefix length. Retrieved from MIRA API",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"prefix_length": {
+							Description: "The prefix length of the subnets counted",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+						"netmask": {
+							Description: "The netmask of the subnets counted",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"total": {
+							Description: "The number of subnets of this size that fit in the range",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+						"allocated": {
+							Description: "The number of subnets of this size that are not free",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+						"free": {
+							Description: "The number of subnets of this size that mira reports as free, and that can be assigned",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+						"excluded": {
+							Description: "The number of subnets of this size that mira reports as free, but that overlap an exclude_cidrs block of the provider, so are never assigned",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+					},
+				},
+			},
+			"largest_free_block": {
+				Description: "The largest free block in the range in CIDR notation, empty when nothing is free",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"largest_free_prefix_length": {
+				Description: "The prefix length of the largest free block, 0 when nothing is free",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// =========
+// CRUD READ
+// =========
+
+func dataSourceMiraRangeUtilizationRead(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	// ----------
+	// GET CLIENT
+	// ----------
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	// ------------------------
+	// GET FIELDS FROM RESOURCE
+	// ------------------------
+
+	requestRange := data.Get("request_range").(string)
+	rangeMask := data.Get("range_mask").(string)
+
+	var prefixLengths []int
+	for _, prefixLength := range data.Get("prefix_lengths").([]interface{}) {
+		prefixLengths = append(prefixLengths, prefixLength.(int))
+	}
+
+	// -----------------------------------
+	// DO MIRA FREE SUBNETS API REQUEST(S)
+	// -----------------------------------
+
+	// count the free subnets in the range, once for each prefix length
+	utilization, err := client.GetMiraRangeUtilization(&miraclient.MiraRangeUtilizationQueryInput{
+		RequestRange:  requestRange,
+		RangeMask:     rangeMask,
+		PrefixLengths: prefixLengths,
+	})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// -------------------------------
+	// SET RESOURCE WITH API RESPONSES
+	// -------------------------------
+
+	prefixes := make([]interface{}, 0, len(utilization.Prefixes))
+	for _, prefix := range utilization.Prefixes {
+		prefixes = append(prefixes, map[string]interface{}{
+			"prefix_length": prefix.PrefixLength,
+			"netmask":       prefix.Netmask,
+			"total":         prefix.Total,
+			"allocated":     prefix.Allocated,
+			"free":          prefix.Free,
+			"excluded":      prefix.Excluded,
+		})
+	}
+
+	if err := data.Set("utilization", prefixes); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := data.Set("largest_free_block", utilization.LargestFreeBlock); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := data.Set("largest_free_prefix_length", utilization.LargestFreePrefixLength); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// ---------------------------------
+	// SET ID TO UNIX TIME SO ALWAYS NEW
+	// ---------------------------------
+
+	// always run
+	data.SetId(strconv.FormatInt(time.Now().Unix(), 10))
+
+	// ------------------------
+	// RETURN INFO AND WARNINGS
+	// ------------------------
+	return diags
+}
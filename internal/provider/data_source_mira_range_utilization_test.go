@@ -0,0 +1,38 @@
+package mira
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceMiraRangeUtilization(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceMiraRangeUtilization,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"data.mira_range_utilization.foo", "utilization.#", "2"),
+					resource.TestCheckResourceAttr(
+						"data.mira_range_utilization.foo", "utilization.0.prefix_length", "26"),
+					resource.TestCheckResourceAttr(
+						"data.mira_range_utilization.foo", "utilization.0.total", "4"),
+					resource.TestMatchResourceAttr(
+						"data.mira_range_utilization.foo", "largest_free_block", regexp.MustCompile(`^$|/2[67]$`)),
+				),
+			},
+		},
+	})
+}
+
+const testAccDataSourceMiraRangeUtilization = `
+data "mira_range_utilization" "foo" {
+  request_range  = "10.10.0.0"
+  range_mask     = "255.255.255.0"
+  prefix_lengths = [26, 27]
+}
+`
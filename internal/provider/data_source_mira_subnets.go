@@ -0,0 +1,223 @@
+package mira
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	// the db mira client
+	"terraform-provider-mira/miraclient"
+)
+
+// ======================================================================
+// THE FUNCTION ASSIGNED TO THE DATASOURCE IN provider.go [RETURN SCHEMA]
+// ======================================================================
+
+func dataSourceMiraSubnets() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "A data source in the Terraform provider Mira for searching the assigned subnets by name, address id, range, template and labels.",
+
+		// the function in this file assigned to the READ CRUD call
+		ReadContext: dataSourceMiraSubnetsRead,
+
+		// set the resource fields in the schema
+		Schema: map[string]*schema.Schema{
+			// set at least one of these to search mira, the labels are only matched against what mira returns
+			"subnet_name": {
+				Description:  "The name of the subnets to find",
+				Type:         schema.TypeString,
+				Optional:     true,
+				AtLeastOneOf: []string{"subnet_name", "address_id", "request_range", "template"},
+			},
+			"address_id": {
+				Description: "The 7 digit address id of the subnets to find",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"request_range": {
+				Description: "The Mira Range the subnets to find were assigned from",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"template": {
+				Description: "The template of the subnets to find",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"labels": {
+				Description: "Only return the subnets that have every one of these labels, with the same value",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			// these resources are populated via api responses from mira
+			"subnets": {
+				Description: "Every subnet matching the search. Retrieved from MIRA API",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"record_id": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"subnet": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"mask": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"subnet_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"address_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"range": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"template": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"comment": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"labels": {
+							Type:     schema.TypeMap,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"owner": {
+							Description: "Who assigned the subnet, when the provider that assigned it had stamp_ownership set: workspace, repository, resource, provider_version and created",
+							Type:        schema.TypeMap,
+							Computed:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// =========
+// CRUD READ
+// =========
+
+func dataSourceMiraSubnetsRead(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	// ------------------------
+	// GET FIELDS FROM RESOURCE
+	// ------------------------
+
+	queryInput := &miraclient.MiraSubnetSearchQueryInput{
+		SubnetName: data.Get("subnet_name").(string),
+		AddressID:  data.Get("address_id").(string),
+		Range:      data.Get("request_range").(string),
+		Template:   data.Get("template").(string),
+	}
+
+	wantedLabels := map[string]string{}
+	for key, value := range data.Get("labels").(map[string]interface{}) {
+		wantedLabels[key] = value.(string)
+	}
+
+	// -------------------------------------------------
+	// DO MIRA SEARCH, KEEP THE SUBNETS WITH EVERY LABEL
+	// -------------------------------------------------
+
+	found, err := client.SearchMiraSubnets(queryInput)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	flattened := make([]interface{}, 0, len(found))
+	for _, record := range found {
+		comment, _ := miraclient.ParseMiraComment(record.Comments)
+		labels, err := miraclient.MiraCommentLabels(record.Comments)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if !miraLabelsMatch(labels, wantedLabels) {
+			continue
+		}
+
+		// subnets assigned without stamp_ownership have no owner
+		owner := map[string]string{}
+		stamp, err := miraclient.MiraCommentOwner(record.Comments)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if stamp != nil {
+			owner = map[string]string{
+				"workspace":        stamp.Workspace,
+				"repository":       stamp.Repository,
+				"resource":         stamp.Resource,
+				"provider_version": stamp.ProviderVersion,
+				"created":          stamp.Created,
+			}
+		}
+
+		flattened = append(flattened, map[string]interface{}{
+			"record_id":   record.RecordId,
+			"subnet":      record.IpAddress,
+			"mask":        record.IpMask,
+			"subnet_name": record.SubnetName,
+			"address_id":  record.AddressID,
+			"range":       record.Range,
+			"template":    record.Template,
+			"comment":     comment,
+			"labels":      labels,
+			"owner":       owner,
+		})
+	}
+
+	if err := data.Set("subnets", flattened); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// -------------------------------
+	// SET THE ID FROM THE SEARCH USED
+	// -------------------------------
+
+	// the labels are sorted, so the same search always has the same id
+	var labelFilters []string
+	for key, value := range wantedLabels {
+		labelFilters = append(labelFilters, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(labelFilters)
+	data.SetId(strings.Join([]string{queryInput.SubnetName, queryInput.AddressID, queryInput.Range, queryInput.Template, strings.Join(labelFilters, ",")}, "/"))
+
+	// ------------------------
+	// RETURN INFO AND WARNINGS
+	// ------------------------
+	return diags
+}
+
+// func to test if a subnet has every wanted label, with the same value
+func miraLabelsMatch(labels map[string]string, wanted map[string]string) bool {
+	for key, value := range wanted {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
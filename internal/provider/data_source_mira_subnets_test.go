@@ -0,0 +1,49 @@
+package mira
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceMiraSubnets(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceMiraSubnets,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"data.mira_subnets.foo", "subnets.0.labels.env", "prd"),
+				),
+			},
+		},
+	})
+}
+
+const testAccDataSourceMiraSubnets = `
+data "mira_subnets" "foo" {
+  request_range = "10.10.0.0"
+  labels = {
+    env = "prd"
+  }
+}
+`
+
+func TestMiraLabelsMatch(t *testing.T) {
+	labels := map[string]string{"env": "prd", "owner": "team-a"}
+
+	if !miraLabelsMatch(labels, map[string]string{}) {
+		t.Fatal("expected no wanted labels to match every subnet")
+	}
+	if !miraLabelsMatch(labels, map[string]string{"env": "prd"}) {
+		t.Fatal("expected a subset of the labels to match")
+	}
+	if miraLabelsMatch(labels, map[string]string{"env": "dev"}) {
+		t.Fatal("expected a different value not to match")
+	}
+	if miraLabelsMatch(labels, map[string]string{"cost_centre": "1234"}) {
+		t.Fatal("expected a missing label not to match")
+	}
+}
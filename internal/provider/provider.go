@@ -2,10 +2,13 @@ package mira
 
 import (
 	"context"
-	// "os"
+	"fmt"
+	"os"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	"terraform-provider-mira/miraclient"
 )
@@ -46,12 +49,280 @@ func New(version string) func() *schema.Provider {
 				// 	Optional:    true,
 				// 	DefaultFunc: schema.EnvDefaultFunc(os.Getenv("MIRA_PASSWORD"), nil),
 				// },
+				"read_only": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "Refuse every change to MIRA without contacting it, for plan only pipelines with read only credentials. Data sources and refreshes keep working, and an apply fails on the first change",
+				},
+				"dry_run": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "Query the free subnets and choose one as an apply would, but never post the assignment. The chosen subnet is saved with simulated set to true and a warning, and destroying it only removes it from the state. Every other change to MIRA, eg: of a mira_ip_address or mira_dhcp_scope, is refused. Once dry_run is turned off, the simulated subnets are removed from state on the next refresh and assigned for real",
+				},
+				"preview_allocation": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "Query the free subnets at plan time, and show the subnet each new mira_allocated_subnet_resource would be assigned as its planned_subnet. The apply assigns the planned subnet while it is still free, and warns when it assigns another. New subnets from the same range in one plan are all predicted the first free subnet, so all but one of them are assigned another, and with preview_strict the plan fails",
+				},
+				"preview_strict": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "Fail the apply of a subnet instead of assigning another one, when its planned_subnet is no longer free. Plan fails too when the prediction can not be made. As every new subnet from the same range is predicted the same subnet, whatever its mask, a plan with more than one new subnet from a range fails, so apply them one at a time. Only used with preview_allocation",
+				},
+				"max_allocation_attempts": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					Default:     miraclient.DefaultMaxAllocationAttempts,
+					Description: "The number of free subnets an assignment tries before it fails, when the subnets it chose are taken outside terraform",
+				},
+				"journal_path": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("MIRA_JOURNAL_PATH", ""),
+					Description: "A local file to journal every assignment post in, before it is sent and once it is saved to state. Pending entries are checked against MIRA when the provider starts, and subnets that were assigned but never saved are reported, to be adopted or released by a mira_journal_release resource. Can also be set with the `MIRA_JOURNAL_PATH` environment variable",
+				},
+				"journal_release_orphans": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Deprecated:  "the provider starts for every plan, so it no longer releases orphans, use a mira_journal_release resource to release them at apply",
+					Description: "Ignored, the orphaned subnets found in the journal are only reported when the provider starts. Use a mira_journal_release resource to release them at apply",
+				},
+				"stamp_ownership": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "Append who owns each new subnet to its MIRA comments, as ` #owner{...}` with the terraform workspace (from `TF_WORKSPACE`), the repository_url, the resource type, the provider version and the creation time. Terraform does not tell providers the address of a resource, eg: `module.net.mira_allocated_subnet_resource.nodes`, so the resource type is recorded in its place, and the subnet name and workspace identify the resource. The block is never compared with the configuration, so it causes no diff",
+				},
+				"naming": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					MaxItems:    1,
+					Description: "The naming convention of the subnets assigned by mira_allocated_subnet_resource. A resource without a subnet_name or comment has them generated from its naming_inputs",
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"subnet_name_template": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "A Go template that generates the subnet_name from the naming_inputs of a resource, eg: `{{.env}}-{{.app}}-{{.region}}-{{.nn}}`",
+							},
+							"subnet_name_pattern": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.StringIsValidRegExp,
+								Description:  "A regular expression every new subnet_name must match in full, checked at plan time, eg: `(dev|uat|prd)-[a-z0-9]+-[a-z]+[0-9]-[0-9]{2}`",
+							},
+							"comment_template": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "A Go template that generates the comment from the naming_inputs of a resource and its subnet_name, eg: `{{.subnet_name}}`",
+							},
+						},
+					},
+				},
+				"defaults": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					MaxItems:    1,
+					Description: "Values for the fields a mira_allocated_subnet_resource leaves out, the values used are shown in the plan of each resource",
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"address_id": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "The address_id of a resource that leaves it out",
+							},
+							"template": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "The template of a resource that leaves it out",
+							},
+							"request_range": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.IsIPv4Address,
+								Description:  "The request_range of a resource that leaves it out",
+							},
+							"request_mask": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.IsIPv4Address,
+								Description:  "The request_mask of a resource that leaves it out",
+							},
+							"comment_prefix": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "Put in front of the comment of every new subnet, the comment sent to MIRA is shown as mira_comment",
+							},
+						},
+					},
+				},
+				"range_catalog": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Description: "Named MIRA ranges, so a mira_allocated_subnet_resource can give a range_name instead of the range, mask, template and address_id. Replaces the map of ranges each module used to copy",
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"name": {
+								Type:        schema.TypeString,
+								Required:    true,
+								Description: "The name resources use for the range, eg: `eu-region3-prd`",
+							},
+							"request_range": {
+								Type:         schema.TypeString,
+								Required:     true,
+								ValidateFunc: validation.IsIPv4Address,
+								Description:  "The MIRA range to assign subnets from",
+							},
+							"request_mask": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.IsIPv4Address,
+								Description:  "The request_mask of the subnets assigned from the range",
+							},
+							"template": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "The template of the subnets assigned from the range",
+							},
+							"address_id": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "The address_id of the subnets assigned from the range",
+							},
+						},
+					},
+				},
+				"allowed_templates": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Description: "The only templates this provider may assign subnets with, eg: `[\"U25_DEV_GCP\"]` for a provider with the DEV credentials. Checked at plan time and again before every assignment",
+					Elem:        &schema.Schema{Type: schema.TypeString},
+				},
+				"allowed_ranges": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Description: "The only ranges this provider may assign subnets from, as range addresses or as CIDRs holding ranges. Checked at plan time and again before every assignment",
+					Elem: &schema.Schema{
+						Type:         schema.TypeString,
+						ValidateFunc: validation.Any(validation.IsIPv4Address, validation.IsCIDR),
+					},
+				},
+				"denied_ranges": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Description: "Ranges this provider may never assign subnets from, as range addresses or as CIDRs holding ranges, even when they are in allowed_ranges",
+					Elem: &schema.Schema{
+						Type:         schema.TypeString,
+						ValidateFunc: validation.Any(validation.IsIPv4Address, validation.IsCIDR),
+					},
+				},
+				"exclude_cidrs": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Description: "Reserved blocks, eg: interconnects and DR, that MIRA still reports as free. A free subnet overlapping any of them is never chosen, and is left out of the payload of mira_available_subnet_data_source",
+					Elem: &schema.Schema{
+						Type:         schema.TypeString,
+						ValidateFunc: validation.IsCIDR,
+					},
+				},
+				"quota": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Description: "Caps on the subnets held by the assignments matching every selector set in the block, eg: one address_id. The subnets held are counted with a MIRA search before every assignment, which fails when one more subnet would go over a limit",
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"address_id": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "Count the subnets of this address id",
+							},
+							"template": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "Count the subnets of this template",
+							},
+							"request_range": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.IsIPv4Address,
+								Description:  "Count the subnets assigned from this MIRA range",
+							},
+							"max_count": {
+								Type:         schema.TypeInt,
+								Optional:     true,
+								ValidateFunc: validation.IntAtLeast(0),
+								Description:  "The most subnets that may be held, 0 is no limit",
+							},
+							"max_addresses": {
+								Type:         schema.TypeInt,
+								Optional:     true,
+								ValidateFunc: validation.IntAtLeast(0),
+								Description:  "The most addresses the subnets held may have in total, 0 is no limit",
+							},
+						},
+					},
+				},
+				"change_gate": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					MaxItems:    1,
+					Description: "Templates and ranges, eg: the production ones, that can only be assigned from with an approved change ticket. A plan creating or changing a gated subnet or subnet group, or a mira_ip_address or mira_dhcp_scope in a gated subnet, fails without a change_ticket matching the ticket_pattern, and the ticket is saved in the MIRA comments field. Terraform does not ask providers to plan a destroy, so destroying a mira_ip_address or mira_dhcp_scope, the release of members when a subnet group rolls back, and the release of orphans by mira_journal_release are checked at apply, before anything is sent to MIRA. Destroying a mira_allocated_subnet or mira_subnet_group never releases the subnet, so it is not gated",
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"templates": {
+								Type:        schema.TypeList,
+								Optional:    true,
+								Description: "The templates that need a change ticket",
+								Elem:        &schema.Schema{Type: schema.TypeString},
+							},
+							"ranges": {
+								Type:        schema.TypeList,
+								Optional:    true,
+								Description: "The ranges that need a change ticket, as range addresses or as CIDRs holding ranges",
+								Elem: &schema.Schema{
+									Type:         schema.TypeString,
+									ValidateFunc: validation.Any(validation.IsIPv4Address, validation.IsCIDR),
+								},
+							},
+							"ticket_pattern": {
+								Type:         schema.TypeString,
+								Required:     true,
+								ValidateFunc: validation.StringIsValidRegExp,
+								Description:  "A regular expression every change ticket must match as a whole, eg: `CHG[0-9]{7}`",
+							},
+							"ticket_env_var": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "An environment variable holding the change ticket, used when a resource has no change_ticket, eg: `MIRA_CHANGE_TICKET` set by the pipeline of an approved change",
+							},
+						},
+					},
+				},
+				"repository_url": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("MIRA_REPOSITORY_URL", ""),
+					Description: "The repository holding the terraform configuration, stamped on new subnets when stamp_ownership is true. Can also be set with the `MIRA_REPOSITORY_URL` environment variable",
+				},
 			},
 			DataSourcesMap: map[string]*schema.Resource{
 				"mira_available_subnet_data_source": dataSourceMiraAvailableSubnets(),
+				"mira_range_utilization":            dataSourceMiraRangeUtilization(),
+				"mira_address":                      dataSourceMiraAddress(),
+				"mira_subnets":                      dataSourceMiraSubnets(),
+				"mira_range_catalog":                dataSourceMiraRangeCatalog(),
 			},
 			ResourcesMap: map[string]*schema.Resource{
 				"mira_allocated_subnet_resource": resourceMiraAllocatedSubnet(),
+				"mira_ip_address":                resourceMiraIPAddress(),
+				"mira_dhcp_scope":                resourceMiraDhcpScope(),
+				"mira_subnet_group":              resourceMiraSubnetGroup(),
+				"mira_journal_release":           resourceMiraJournalRelease(),
 			},
 		}
 
@@ -76,7 +347,7 @@ func New(version string) func() *schema.Provider {
 //}
 
 func configure(version string, p *schema.Provider) func(context.Context, *schema.ResourceData) (interface{}, diag.Diagnostics) {
-	return func(context.Context, *schema.ResourceData) (interface{}, diag.Diagnostics) {
+	return func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 		// create new client from miraclient package, 
 		// the new client does not take any variables,
 		// it uses os.Getenv to collect the credentials 
@@ -85,6 +356,161 @@ func configure(version string, p *schema.Provider) func(context.Context, *schema
 		if err != nil {
 			return nil, diag.FromErr(err)
 		}
-		return apiClient, nil
+
+		// the provider settings below change how the client behaves
+		apiClient.MaxAllocationAttempts = d.Get("max_allocation_attempts").(int)
+		apiClient.ReadOnly = d.Get("read_only").(bool)
+		apiClient.DryRun = d.Get("dry_run").(bool)
+		apiClient.PreviewAllocation = d.Get("preview_allocation").(bool)
+		apiClient.PreviewStrict = d.Get("preview_strict").(bool)
+
+		// stamp new subnets with their owner, terraform sets TF_WORKSPACE for every workspace but the default
+		if d.Get("stamp_ownership").(bool) {
+			workspace := os.Getenv("TF_WORKSPACE")
+			if workspace == "" {
+				workspace = "default"
+			}
+			apiClient.Ownership = &miraclient.Ownership{
+				Workspace:       workspace,
+				Repository:      d.Get("repository_url").(string),
+				ProviderVersion: version,
+			}
+		}
+
+		// parse the naming convention, a broken template fails every plan so it fails here
+		if namingBlocks := d.Get("naming").([]interface{}); len(namingBlocks) > 0 && namingBlocks[0] != nil {
+			naming := namingBlocks[0].(map[string]interface{})
+			apiClient.Naming, err = miraclient.NewNamingConvention(naming["subnet_name_template"].(string), naming["subnet_name_pattern"].(string), naming["comment_template"].(string))
+			if err != nil {
+				return nil, diag.FromErr(err)
+			}
+		}
+
+		// the defaults of the fields a resource leaves out
+		if defaultsBlocks := d.Get("defaults").([]interface{}); len(defaultsBlocks) > 0 && defaultsBlocks[0] != nil {
+			defaults := defaultsBlocks[0].(map[string]interface{})
+			apiClient.Defaults = miraclient.SubnetDefaults{
+				AddressID:     defaults["address_id"].(string),
+				Template:      defaults["template"].(string),
+				RequestRange:  defaults["request_range"].(string),
+				RequestMask:   defaults["request_mask"].(string),
+				CommentPrefix: defaults["comment_prefix"].(string),
+			}
+		}
+
+		// the named ranges, a name given twice would make range_name ambiguous
+		apiClient.RangeCatalog = map[string]miraclient.CatalogRange{}
+		for _, entry := range d.Get("range_catalog").([]interface{}) {
+			catalogRange := entry.(map[string]interface{})
+			name := catalogRange["name"].(string)
+			if _, ok := apiClient.RangeCatalog[name]; ok {
+				return nil, diag.Errorf("the range_catalog has more than one range named %q", name)
+			}
+			apiClient.RangeCatalog[name] = miraclient.CatalogRange{
+				Name:         name,
+				RequestRange: catalogRange["request_range"].(string),
+				RequestMask:  catalogRange["request_mask"].(string),
+				Template:     catalogRange["template"].(string),
+				AddressID:    catalogRange["address_id"].(string),
+			}
+		}
+
+		// the templates and ranges this provider may assign from
+		apiClient.Policy = miraclient.AssignmentPolicy{
+			AllowedTemplates: expandStringList(d.Get("allowed_templates").([]interface{})),
+			AllowedRanges:    expandStringList(d.Get("allowed_ranges").([]interface{})),
+			DeniedRanges:     expandStringList(d.Get("denied_ranges").([]interface{})),
+		}
+
+		// the reserved blocks that are never chosen
+		apiClient.ExcludeCIDRs = expandStringList(d.Get("exclude_cidrs").([]interface{}))
+
+		// the templates and ranges that need a change ticket
+		if gateBlocks := d.Get("change_gate").([]interface{}); len(gateBlocks) > 0 && gateBlocks[0] != nil {
+			gate := gateBlocks[0].(map[string]interface{})
+			apiClient.ChangeGate, err = miraclient.NewChangeGate(expandStringList(gate["templates"].([]interface{})), expandStringList(gate["ranges"].([]interface{})), gate["ticket_pattern"].(string), gate["ticket_env_var"].(string))
+			if err != nil {
+				return nil, diag.FromErr(err)
+			}
+		}
+
+		// the caps on the subnets held, each needs a selector to search with and a limit to check
+		for _, entry := range d.Get("quota").([]interface{}) {
+			block := entry.(map[string]interface{})
+			quota := miraclient.AssignmentQuota{
+				AddressID:    block["address_id"].(string),
+				Template:     block["template"].(string),
+				RequestRange: block["request_range"].(string),
+				MaxCount:     block["max_count"].(int),
+				MaxAddresses: block["max_addresses"].(int),
+			}
+			if quota.AddressID == "" && quota.Template == "" && quota.RequestRange == "" {
+				return nil, diag.Errorf("every quota needs at least one of address_id, template or request_range")
+			}
+			if quota.MaxCount == 0 && quota.MaxAddresses == 0 {
+				return nil, diag.Errorf("the quota for %s needs max_count or max_addresses", quota.String())
+			}
+			apiClient.Quotas = append(apiClient.Quotas, quota)
+		}
+
+		// turn on the journal, and report what was left pending by earlier applies
+		var diags diag.Diagnostics
+		if journalPath := d.Get("journal_path").(string); journalPath != "" {
+			apiClient.Journal = miraclient.OpenJournal(journalPath)
+			diags = append(diags, reconcileMiraJournal(apiClient)...)
+		}
+
+		return apiClient, diags
+	}
+}
+
+// ----------------------------------------------------------------
+// CHECK THE JOURNAL AGAINST MIRA, RETURN A WARNING FOR EACH ORPHAN
+// ----------------------------------------------------------------
+
+func reconcileMiraJournal(apiClient *miraclient.Client) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	// the provider starts for every plan, so orphans are only reported here, never released, and
+	// only entries older than an hour are looked up in mira, so a clean journal costs no requests
+	// the journal is only there to recover from failures, so a problem with it never stops the provider
+	reconciliation, err := apiClient.ReconcileJournal(false, "")
+	if err != nil {
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Could not check the mira journal",
+			Detail:   err.Error(),
+		})
+	}
+
+	return append(diags, miraJournalOrphanDiagnostics(reconciliation.Orphans)...)
+}
+
+// func to warn about each orphan found in the journal, with how to adopt or release it
+func miraJournalOrphanDiagnostics(orphans []miraclient.JournalEntry) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, entry := range orphans {
+		release := "Set adopt_existing on the resource to adopt it, or apply a mira_journal_release resource to release it."
+
+		// the apply stopped before the record was read back, so it can not be matched to be released
+		if entry.RecordId == 0 {
+			release = "Its record was never read back, so mira_journal_release leaves it alone. Check it in mira, then set adopt_existing on the resource to adopt it, or release it in mira."
+		}
+
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Subnet %s is assigned in mira but was never saved to terraform state", entry.Subnet),
+			Detail:   fmt.Sprintf("An apply at %s assigned %s (mask %s, name %q) from range %s and stopped before saving it. %s", entry.Timestamp.Format(time.RFC3339), entry.Subnet, entry.Mask, entry.SubnetName, entry.Range, release),
+		})
+	}
+	return diags
+}
+
+// func to turn a list from the provider schema into strings
+func expandStringList(list []interface{}) []string {
+	expanded := make([]string, 0, len(list))
+	for _, value := range list {
+		expanded = append(expanded, value.(string))
 	}
+	return expanded
 }
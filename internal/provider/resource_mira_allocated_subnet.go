@@ -2,10 +2,17 @@ package mira
 
 import (
 	"context"
-	// "errors"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	// the db mira client
 	"terraform-provider-mira/miraclient"
@@ -26,50 +33,226 @@ func resourceMiraAllocatedSubnet() *schema.Resource {
 		UpdateContext: resourceMiraAllocatedSubnetUpdate,
 		DeleteContext: resourceMiraAllocatedSubnetDelete,
 
+		// checks against mira that are run at plan time
+		CustomizeDiff: customdiff.All(
+			resourceMiraAllocatedSubnetNaming,
+			resourceMiraAllocatedSubnetDefaults, // after naming, as the comment prefix goes on a generated comment too
+			resourceMiraAllocatedSubnetPolicy,   // after defaults, so the template and range used are checked
+			resourceMiraAllocatedSubnetChangeGate,
+			resourceMiraAllocatedSubnetValidateAddressID,
+			resourceMiraAllocatedSubnetPreview,  // last, so the subnet is only predicted for a plan that passed every check
+		),
+
+		// the id is the mira record id from version 1, version 0 ids were "subnet-mask" or "range-mask",
+		// and version 2 added the snake_case names of the attributes
+		SchemaVersion: 2,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    resourceMiraAllocatedSubnetV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceMiraAllocatedSubnetStateUpgradeV0,
+			},
+			{
+				// version 1 only changed the id, so its attributes are those of version 0
+				Version: 1,
+				Type:    resourceMiraAllocatedSubnetV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceMiraAllocatedSubnetStateUpgradeV1,
+			},
+		},
+
+		// searchFreeSubnet on a large range, and an assignment mira answers as pending, can take minutes
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Read:   schema.DefaultTimeout(2 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+
 		// the resources schema map of its fields
 		Schema: map[string]*schema.Schema{
 			// populate these fields from text in terraform hcl [resources]
-			"addressid": {
+			"address_id": {
 				Type:         schema.TypeString,
-				Required:     true, // require fields are populated in terraform
+				Optional:     true, // one of address_id, the deprecated addressid or the provider default is required
+				Computed:     true, // kept in step with its deprecated alias, so switching names is not a change
+				ConflictsWith: []string{"addressid"},
 				Description: "A 7 digit integer that identifies the physical location in the world (a site id for an address)",
 			},
+			"addressid": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				Deprecated:   "use address_id instead, addressid will be removed in a future release",
+				Description: "Deprecated alias of address_id",
+			},
 			"comment": {
 				Type:         schema.TypeString,
-				Required:     true,
-				Description: "A description for the subnet use",
+				Optional:     true, // required, unless the provider naming has a comment_template
+				Computed:     true,
+				Description: "A description for the subnet use. Generated from the naming_inputs when it is left out and the provider naming has a comment_template",
 			},
-			"requestrange": {
+			"naming_inputs": {
+				Type:         schema.TypeMap,
+				Optional:     true,
+				Description: "The inputs of the provider naming templates, eg: `{ env = \"prd\", app = \"shop\", region = \"euw3\", nn = \"01\" }`",
+				Elem:         &schema.Schema{Type: schema.TypeString},
+			},
+			"request_range": {
 				Type:         schema.TypeString,
-				Required:     true,
+				Optional:     true,
+				Computed:     true,
+				ConflictsWith: []string{"requestrange"},
 				Description: "!!IMPORTANT!! Mira Range from which to assign a subnet",
 			},
-			"requestmask": {
+			"requestrange": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				Deprecated:   "use request_range instead, requestrange will be removed in a future release",
+				Description: "Deprecated alias of request_range",
+			},
+			"request_mask": {
 				Type:         schema.TypeString,
-				Required:     true,
+				Optional:     true,
+				Computed:     true,
+				ConflictsWith: []string{"requestmask"},
 				Description: "!!IMPORTANT!! Subnet mask for Mira Range from which to assign a subnet",
 			},
+			"requestmask": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				Deprecated:   "use request_mask instead, requestmask will be removed in a future release",
+				Description: "Deprecated alias of request_mask",
+			},
+			"subnet_name": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ConflictsWith: []string{"subnetname"},
+				Description: "A description matching the comment field. Required, unless the provider naming has a subnet_name_template to generate it from the naming_inputs",
+			},
 			"subnetname": {
 				Type:         schema.TypeString,
-				Required:     true,
-				Description: "A description matching the comment field",
+				Optional:     true,
+				Computed:     true,
+				Deprecated:   "use subnet_name instead, subnetname will be removed in a future release",
+				Description: "Deprecated alias of subnet_name",
 			},
 			"template": {
 				Type:         schema.TypeString,
-				Required:     true,
+				Optional:     true, // required, unless the provider defaults have a template
+				Computed:     true,
 				Description: "One of the values: U25_DEV_GCP U25_UAT_GCP U25_PRD_GCP which are required preconfigured template names",
 			},
+			"labels": {
+				Type:         schema.TypeMap,
+				Optional:     true,
+				Description: "Labels for the subnet, eg: owner, cost centre, repository and environment. MIRA has no labels, so they are saved after the comment in the MIRA comments field as ` #labels{...}` with the labels as a JSON object, and read back from it to detect changes made outside terraform. A change to the labels alone is written back to MIRA",
+				Elem:         &schema.Schema{Type: schema.TypeString},
+			},
+			"change_ticket": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				Description: "The approved change ticket of the assignment, required for the templates and ranges in the provider change_gate and saved after the comment in the MIRA comments field as ` #change{...}`. Taken from the ticket_env_var of the change_gate when left out",
+			},
+			"range_name": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"request_range", "requestrange"},
+				Description:  "The name of a range in the provider range_catalog, which fills in the request_range, and the request_mask, template and address_id when they are left out",
+			},
+			"exclude_cidrs": {
+				Type:         schema.TypeList,
+				Optional:     true,
+				Description: "Reserved blocks that MIRA still reports as free, on top of the exclude_cidrs of the provider. A free subnet overlapping any of them is never chosen",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.IsCIDR,
+				},
+			},
+			"free_capacity_warning_threshold": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				Description: "Warn when fewer than this many subnets of the request_mask size are left free in the request_range after allocation. 0 disables the check",
+			},
+			"adopt_existing": {
+				Type:         schema.TypeBool,
+				Optional:     true,
+				Default:      false,
+				Description: "Before assigning a new subnet, search MIRA for a subnet with the same subnet_name, address_id and request_range, and adopt it into state when exactly one is found. Use this to recover a subnet that was assigned by an apply that was interrupted before it saved state",
+			},
+			"qip": {
+				Type:         schema.TypeList,
+				Optional:     true,
+				MaxItems:     1,
+				Description: "Push the subnet to QIP for DNS registration, optionally with a DHCP scope. A change to dhcp, dhcp_server or dhcp_template puts or removes the DHCP scope in place, adding or removing the block on an assigned subnet is not implemented",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"dhcp": {
+							Type:         schema.TypeBool,
+							Optional:     true,
+							Default:      false,
+							Description: "Serve a DHCP scope for the subnet from QIP",
+						},
+						"dhcp_server": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description: "The QIP DHCP server to serve the scope from, required when dhcp is true",
+						},
+						"dhcp_template": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description: "The QIP DHCP template to create the scope with",
+						},
+					},
+				},
+			},
 			// IMPORTANT: following values are populated by the api calls to MIRA
-			"miraassignedsubnet": {
+			"assigned_subnet": {
 				Type:         schema.TypeString,
 				Computed:     true, // fields are populated via api response from mira
-				Description: "A subnet from within the requestrange, assigned by mira to this projects network",
+				Description: "A subnet from within the request_range, assigned by mira to this projects network",
 			},
-			"miraassignedsubnetmask": {
+			"miraassignedsubnet": {
+				Type:         schema.TypeString,
+				Computed:     true,
+				Deprecated:   "use assigned_subnet instead, miraassignedsubnet will be removed in a future release",
+				Description: "Deprecated alias of assigned_subnet",
+			},
+			"assigned_subnet_mask": {
 				Type:         schema.TypeString,
 				Computed:     true,
 				Description: "A subnetmask, assigned by mira to this projects network",
 			},
+			"miraassignedsubnetmask": {
+				Type:         schema.TypeString,
+				Computed:     true,
+				Deprecated:   "use assigned_subnet_mask instead, miraassignedsubnetmask will be removed in a future release",
+				Description: "Deprecated alias of assigned_subnet_mask",
+			},
+			"mira_comment": {
+				Type:         schema.TypeString,
+				Computed:     true,
+				Description: "The comment saved in MIRA, with the comment_prefix of the provider defaults in front, and without the labels and owner blocks",
+			},
+			"qip_instance": {
+				Type:         schema.TypeString,
+				Computed:     true,
+				Description: "The QIP instance the subnet has been pushed to, empty when it is not in QIP",
+			},
+			"planned_subnet": {
+				Type:         schema.TypeString,
+				Computed:     true,
+				Description: "The subnet predicted at plan time when the provider has preview_allocation set, which the apply assigns while it is still free. Empty when no prediction was made. Every new subnet from the same range in a plan is predicted the same first free subnet, so only one of them is assigned its planned_subnet, and with preview_strict the plan only allows one new subnet per range",
+			},
+			"simulated": {
+				Type:         schema.TypeBool,
+				Computed:     true,
+				Description: "True when the subnet was chosen by a provider with dry_run set, so it was never assigned in MIRA",
+			},
 
 			// Commented out: not required for the functionality used, eg: ip and nm octets are split by the client; the
 			//                two miraassigned* resources above hold the ip address string (thats been checked by the client)
@@ -200,12 +383,69 @@ func resourceMiraAllocatedSubnetCreate(ctx context.Context, data *schema.Resourc
 	// GET FIELDS FROM RESOURCE
 	// ------------------------
 
-	requestRange	 := data.Get("requestrange").(string)
-	requestMask	 := data.Get("requestmask").(string)
-	addressID	 := data.Get("addressid").(string)
+	requestRange	 := getMiraAllocatedSubnetString(data, "request_range")
+	requestMask	 := getMiraAllocatedSubnetString(data, "request_mask")
+	addressID	 := getMiraAllocatedSubnetString(data, "address_id")
 	comment		 := data.Get("comment").(string)
-	subnetName       := data.Get("subnetname").(string)
+	subnetName       := getMiraAllocatedSubnetString(data, "subnet_name")
 	template         := data.Get("template").(string)
+	threshold        := data.Get("free_capacity_warning_threshold").(int)
+
+	// the naming convention and defaults fill these in at plan time, so they are only empty without them
+	if subnetName == "" || comment == "" || requestRange == "" || requestMask == "" || addressID == "" || template == "" {
+		return diag.Errorf("subnet_name, comment, request_range, request_mask, address_id and template are required, unless the provider naming or defaults set them")
+	}
+
+	// the comment sent to mira starts with the prefix of the provider defaults
+	comment = client.Defaults.CommentPrefix + comment
+	if err := data.Set("mira_comment", comment); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// save each field under both of its names, so either name can be used in the config
+	for name, value := range map[string]string{"request_range": requestRange, "request_mask": requestMask, "address_id": addressID, "subnet_name": subnetName} {
+		if err := setMiraAllocatedSubnetString(data, name, value); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// -------------------------------------------------
+	// ADOPT AN EXISTING ASSIGNMENT INSTEAD OF A NEW ONE
+	// -------------------------------------------------
+
+	if data.Get("adopt_existing").(bool) {
+		existing, err := client.SearchMiraSubnets(&miraclient.MiraSubnetSearchQueryInput{
+			SubnetName: subnetName,
+			AddressID:  addressID,
+			Range:      requestRange,
+		})
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		switch len(existing) {
+		case 0:
+			// nothing to adopt, so carry on and assign a new subnet
+		case 1:
+			diags = append(diags, resourceMiraAllocatedSubnetAdopt(data, &existing[0])...)
+			// an adopted subnet is usually one left pending in the journal by an interrupted apply
+			if err := client.Journal.CommitSubnet(existing[0].IpAddress); err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Warning,
+					Summary:  "Could not commit the adopted subnet to the mira journal",
+					Detail:   err.Error(),
+				})
+			}
+			// fill the computed fields and the labels from the adopted record
+			return append(diags, resourceMiraAllocatedSubnetRead(ctx, data, meta)...)
+		default:
+			var found []string
+			for _, record := range existing {
+				found = append(found, record.IpAddress)
+			}
+			return diag.Errorf("adopt_existing found %d subnets named %q for address_id %s in range %s, expected at most one: %s", len(existing), subnetName, addressID, requestRange, strings.Join(found, ", "))
+		}
+	}
 
 	// write logs using the tflog package
 	// see https://pkg.go.dev/github.com/hashicorp/terraform-plugin-log/tflog
@@ -218,20 +458,53 @@ func resourceMiraAllocatedSubnetCreate(ctx context.Context, data *schema.Resourc
 
 	// add the mira range and netmask and other details to the mira assignment datastructure
 	miraAssignSubnetRequestInput := &miraclient.MiraSubnetAssignmentPostInput{
-		RequestRange: requestRange,
-		RequestMask:  requestMask,
-		AddressID:    addressID,
-		Comment:      comment,
-		SubnetName:   subnetName,
-		Template:     template,
+		RequestRange:   requestRange,
+		RequestMask:    requestMask,
+		AddressID:      addressID,
+		Comment:        comment,
+		SubnetName:     subnetName,
+		Template:       template,
+		Timeout:        data.Timeout(schema.TimeoutCreate),
+		Labels:         map[string]string{},
+		ResourceType:   "mira_allocated_subnet_resource",
+		ExpectedSubnet: data.Get("planned_subnet").(string),
+		ChangeTicket:   data.Get("change_ticket").(string),
+		ExcludeCIDRs:   expandStringList(data.Get("exclude_cidrs").([]interface{})),
+	}
+	for key, value := range data.Get("labels").(map[string]interface{}) {
+		miraAssignSubnetRequestInput.Labels[key] = value.(string)
+	}
+
+	// a qip block pushes the subnet to qip, and its dhcp fields add a dhcp scope
+	if qipBlocks := data.Get("qip").([]interface{}); len(qipBlocks) > 0 {
+		miraAssignSubnetRequestInput.AlsoQip = true
+		if qip, ok := qipBlocks[0].(map[string]interface{}); ok {
+			miraAssignSubnetRequestInput.Dhcp         = qip["dhcp"].(bool)
+			miraAssignSubnetRequestInput.DhcpServer   = qip["dhcp_server"].(string)
+			miraAssignSubnetRequestInput.DhcpTemplate = qip["dhcp_template"].(string)
+		}
+		if miraAssignSubnetRequestInput.Dhcp && miraAssignSubnetRequestInput.DhcpServer == "" {
+			return diag.Errorf("qip.dhcp_server is required when qip.dhcp is true")
+		}
 	}
 
 	// assign a free subnet from within the RequestRange to this project, to do this we hit the 
 	// get free subnets from mira range api endpoint and then choose the first available subnet
 	// and submit this to mira via a post request to create the assignment, return subnet/error
-	chosenSubnet, err := client.CreateMiraSubnetAssignment(miraAssignSubnetRequestInput)
-	if err != nil {
-		return diag.FromErr(err)
+	assignment, err := client.CreateMiraSubnetAssignment(miraAssignSubnetRequestInput)
+	diags = append(diags, miraAssignmentDiagnostics(assignment, err)...)
+	if diags.HasError() {
+		return diags
+	}
+	chosenSubnet := assignment.Subnet
+
+	// the reviewed plan showed another subnet, which was taken between the plan and the apply
+	if planned := miraAssignSubnetRequestInput.ExpectedSubnet; planned != "" && planned != chosenSubnet {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Subnet %s was assigned instead of the planned subnet %s", chosenSubnet, planned),
+			Detail:   "The planned subnet was no longer free at apply. Set preview_strict in the provider to fail instead of assigning another subnet.",
+		})
 	}
 
 	// IMPORTANT: I am setting the subnet mask here because i dont know where it comes from currently
@@ -243,27 +516,57 @@ func resourceMiraAllocatedSubnetCreate(ctx context.Context, data *schema.Resourc
 	// ---------------------------------------------
 
 	// add the chosen subnet from the api response to the resource field
-	if err := data.Set("miraassignedsubnet", chosenSubnet); err != nil {
+	if err := setMiraAllocatedSubnetString(data, "assigned_subnet", chosenSubnet); err != nil {
 		return diag.FromErr(err)
 	}
 
 	// add the subnet mask of the chosen subnet to the resource field
-	if err := data.Set("miraassignedsubnetmask", chosenSubnetMask); err != nil {
+	if err := setMiraAllocatedSubnetString(data, "assigned_subnet_mask", chosenSubnetMask); err != nil {
 		return diag.FromErr(err)
 	}
 
+	// ------------------------------------------------
+	// A DRY RUN HAS NO RECORD, SO STOP BEFORE THE READ
+	// ------------------------------------------------
+
+	// the simulated subnet is kept in state, with an id that can not be taken for a record id
+	if assignment.Simulated {
+		if err := data.Set("simulated", true); err != nil {
+			return diag.FromErr(err)
+		}
+		data.SetId("simulated-" + chosenSubnet)
+		return diags
+	}
+
 	// --------------------------------------
-	// SET RESOURCE ID TO "SUBNET-SUBNETMASK"
+	// SET RESOURCE ID TO THE MIRA RECORD ID
 	// --------------------------------------
 
 	// run when all conditions are met
-	data.SetId(chosenSubnet + "-" + chosenSubnetMask)
+	data.SetId(strconv.Itoa(assignment.RecordId))
 
-	// ---------------------------------------------------
-	// RETURN DIAGS FOR INFO AND WARNINGS (no errors seen)
-	// ---------------------------------------------------
+	// the subnet is in state now, so close its journal entry
+	if err := client.Journal.Commit(assignment.JournalID); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Could not commit the assignment to the mira journal",
+			Detail:   err.Error(),
+		})
+	}
 
-	return diags
+	// ------------------------------------------------
+	// WARN IF THE RANGE IS RUNNING OUT OF FREE SUBNETS
+	// ------------------------------------------------
+
+	// the allocation has been made, so any problem here is only a warning
+	diags = append(diags, checkMiraRangeFreeCapacity(client, requestRange, requestMask, threshold)...)
+
+	// --------------------------------------------------
+	// READ BACK THE RECORD, RETURN DIAGS FROM BOTH CALLS
+	// --------------------------------------------------
+
+	// fill the computed fields from mira, so the state matches what the next plan reads
+	return append(diags, resourceMiraAllocatedSubnetRead(ctx, data, meta)...)
 }
 
 // =========
@@ -280,61 +583,124 @@ func resourceMiraAllocatedSubnetRead(ctx context.Context, data *schema.ResourceD
 	// --------------
 
 	// use the meta value to retrieve your client from the mira provider configure method
-//	client := meta.(*miraclient.Client)
+	client := meta.(*miraclient.Client)
 
 	// --------------------------------
 	// GET THE FIELDS FROM THE RESOURCE
 	// --------------------------------
 
-//	SubnetAddress	 := data.Get("miraassignedsubnet").(string)
-	// SubnetMask	 := data.Get("miraassignedsubnetmask").(string)  // not used
+	SubnetAddress	 := getMiraAllocatedSubnetString(data, "assigned_subnet")
+
+	// a subnet simulated by a dry run is not in mira, so there is nothing to read, and once the
+	// dry run is turned off it is removed from state, so the next apply assigns it for real
+	if data.Get("simulated").(bool) {
+		if !client.DryRun {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Subnet %s was only simulated by a dry run", SubnetAddress),
+				Detail:   "The provider no longer has dry_run set, so the simulated subnet has been removed from state and will be assigned on the next apply.",
+			})
+			data.SetId("")
+		}
+		return diags
+	}
+
+	// the id is the mira record id, state from before schema version 1 is upgraded to it
+	recordID, err := strconv.Atoi(data.Id())
+	if err != nil {
+		return diag.Errorf("unexpected id %q, expected a mira record id: %s", data.Id(), err)
+	}
 
 	// ---------------------------------------
 	// DO THE API REQUEST TO GET SUBNET RECORD
 	// ---------------------------------------
 
-	// add subnet address to query datastructure
-//	findMiraSubnetByIpQueryInput := &miraclient.GetMiraSubnetFromIPAddressQueryInput{
-//		IpAddress: SubnetAddress,
-//	}
+	// add the record id to query datastructure
+	findMiraSubnetByIDQueryInput := &miraclient.GetMiraSubnetByIDQueryInput{
+		RecordId: recordID,
+		Timeout:  data.Timeout(schema.TimeoutRead),
+	}
 
-	// do the api request to get the subnet record for an ip from MIRA
-//	returnedSubnet, err := client.GetMiraSubnetRecordFromIPAddress(findMiraSubnetByIpQueryInput)
-//	if err != nil {
-//		// return diag.FromErr(err)
-//	}
+	// do the api request to get the subnet record from MIRA
+	returnedSubnet, err := client.GetMiraSubnetRecordByID(findMiraSubnetByIDQueryInput)
 
-	// ---------------
-	// DO SIMPLE CHECK
-	// ---------------
+	// mira can lag behind its own writes, so a subnet we have just created may not be
+	// found yet, retry for the read timeout before deciding it is gone
+	if miraclient.IsNotFound(err) && data.IsNewResource() {
+		err = resource.RetryContext(ctx, data.Timeout(schema.TimeoutRead), func() *resource.RetryError {
+			returnedSubnet, err = client.GetMiraSubnetRecordByID(findMiraSubnetByIDQueryInput)
+			if miraclient.IsNotFound(err) {
+				return resource.RetryableError(err)
+			}
+			if err != nil {
+				return resource.NonRetryableError(err)
+			}
+			return nil
+		})
+	}
 
-	// check returned address matches submitted (terraform should probably do this)
-	// if returnedSubnet.IpAddress != SubnetAddress {
-		// return diag.FromErr(errors.New("Returned Subnet did not match stored subnet"))
-	// }
+	// the subnet has been released outside terraform, so remove it from state
+	if miraclient.IsNotFound(err) && !data.IsNewResource() {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Subnet %s is no longer assigned in mira", SubnetAddress),
+			Detail:   "The subnet was released outside terraform, so it has been removed from state and will be assigned again on the next apply.",
+		})
+		data.SetId("")
+		return diags
+	}
+	if miraclient.IsNotFound(err) {
+		return diag.Errorf("the subnet %s was assigned, but mira did not return its record within the read timeout of %s: %s", SubnetAddress, data.Timeout(schema.TimeoutRead), err)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// the record is in state, so a journal entry left open by a lost commit is closed, and never released
+	if err := client.Journal.CommitRecord(recordID); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Could not commit the subnet to the mira journal",
+			Detail:   err.Error(),
+		})
+	}
 
 	// ---------------------------------------------
 	// SET TERRAFORM RESOURCE DATA WITH API RESPONSE
 	// ---------------------------------------------
 
-	requestRange	 := data.Get("requestrange").(string)
-	requestMask	 := data.Get("requestmask").(string)
-	// add the returned subnet IpAddress to the resource (to be compaired, and evaluate to no change... i think)
-	if err := data.Set("miraassignedsubnet", requestRange); err != nil {
-		// return diag.FromErr(err)
+	// add the subnet and mask from the record, which also corrects a mask guessed by create
+	if err := setMiraAllocatedSubnetString(data, "assigned_subnet", returnedSubnet.IpAddress); err != nil {
+		return diag.FromErr(err)
+	}
+	if returnedSubnet.IpMask != "" {
+		if err := setMiraAllocatedSubnetString(data, "assigned_subnet_mask", returnedSubnet.IpMask); err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
-	// add the returned subnet mask to the resource to be compaired to previous value
-	if err := data.Set("miraassignedsubnetmask", requestMask); err != nil {
-		// return diag.FromErr(err)
+	// add the comment saved in mira, without the blocks after it
+	miraComment, _ := miraclient.ParseMiraComment(returnedSubnet.Comments)
+	if err := data.Set("mira_comment", miraComment); err != nil {
+		return diag.FromErr(err)
 	}
 
-	// --------------------------------------
-	// SET RESOURCE ID TO "SUBNET-SUBNETMASK"
-	// --------------------------------------
+	// add the labels saved in the comments, so a change made in mira shows as drift
+	labels, err := miraclient.MiraCommentLabels(returnedSubnet.Comments)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := data.Set("labels", labels); err != nil {
+		return diag.FromErr(err)
+	}
 
-	// all conditions met, set the resource id
-	data.SetId(requestRange + "-" + requestMask)
+	// add the qip instance the subnet was pushed to, empty when it is not in qip
+	if err := data.Set("simulated", false); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := data.Set("qip_instance", returnedSubnet.QipInstance); err != nil {
+		return diag.FromErr(err)
+	}
 
 	// -----------------------------------------
 	// RETURN INFO AND WARNINGS (no errors seen)
@@ -343,16 +709,625 @@ func resourceMiraAllocatedSubnetRead(ctx context.Context, data *schema.ResourceD
 	return diags
 }
 
+// -----------------------------------------------------------------------
+// ATTRIBUTE NAMES: EACH SNAKE_CASE NAME AND ITS DEPRECATED SQUASHED ALIAS
+// -----------------------------------------------------------------------
+
+// the canonical name of each renamed attribute, and the deprecated alias kept for a release cycle
+var miraAllocatedSubnetAliases = map[string]string{
+	"address_id":           "addressid",
+	"request_range":        "requestrange",
+	"request_mask":         "requestmask",
+	"subnet_name":          "subnetname",
+	"assigned_subnet":      "miraassignedsubnet",
+	"assigned_subnet_mask": "miraassignedsubnetmask",
+}
+
+// get a renamed field by its canonical name, falling back to its deprecated alias
+func getMiraAllocatedSubnetString(data *schema.ResourceData, name string) string {
+	if value, ok := data.GetOk(name); ok {
+		return value.(string)
+	}
+	return data.Get(miraAllocatedSubnetAliases[name]).(string)
+}
+
+// get a renamed field from a plan by its canonical name or its deprecated alias, ok is false while it is not known
+func getMiraAllocatedSubnetDiffString(diff *schema.ResourceDiff, name string) (string, bool) {
+	for _, key := range []string{name, miraAllocatedSubnetAliases[name]} {
+		if diff.NewValueKnown(key) {
+			if value := diff.Get(key).(string); value != "" {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// set a renamed field under both of its names, so state has the same value under each
+func setMiraAllocatedSubnetString(data *schema.ResourceData, name string, value string) error {
+	if err := data.Set(name, value); err != nil {
+		return err
+	}
+	return data.Set(miraAllocatedSubnetAliases[name], value)
+}
+
+// -------------------------------------------------------------
+// PLAN TIME DEFAULTS: FILL IN THE FIELDS LEFT OUT OF THE CONFIG
+// -------------------------------------------------------------
+
+func resourceMiraAllocatedSubnetDefaults(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	client := meta.(*miraclient.Client)
+
+	// a field counts as left out when neither of its names is in the config itself
+	config := diff.GetRawConfig()
+	if config.IsNull() || !config.IsKnown() {
+		return nil
+	}
+
+	defaults := map[string]string{
+		"address_id":    client.Defaults.AddressID,
+		"template":      client.Defaults.Template,
+		"request_range": client.Defaults.RequestRange,
+		"request_mask":  client.Defaults.RequestMask,
+	}
+
+	// a named range of the catalog wins over the provider defaults
+	if !config.GetAttr("range_name").IsNull() {
+		if !diff.NewValueKnown("range_name") {
+			return nil
+		}
+		rangeName := diff.Get("range_name").(string)
+		catalogRange, ok := client.RangeCatalog[rangeName]
+		if !ok {
+			return fmt.Errorf("range_name %q is not in the provider range_catalog, which has: %s", rangeName, strings.Join(miraRangeCatalogNames(client), ", "))
+		}
+		for name, value := range map[string]string{
+			"address_id":    catalogRange.AddressID,
+			"template":      catalogRange.Template,
+			"request_range": catalogRange.RequestRange,
+			"request_mask":  catalogRange.RequestMask,
+		} {
+			if value != "" {
+				defaults[name] = value
+			}
+		}
+	}
+
+	for name, value := range defaults {
+		alias, hasAlias := miraAllocatedSubnetAliases[name]
+		if !config.GetAttr(name).IsNull() || (hasAlias && !config.GetAttr(alias).IsNull()) {
+			continue
+		}
+		if value == "" {
+			return fmt.Errorf("%s is required, unless the provider defaults set it", name)
+		}
+
+		// set the default under both names, so the plan shows the value used
+		if err := diff.SetNew(name, value); err != nil {
+			return err
+		}
+		if hasAlias {
+			if err := diff.SetNew(alias, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	// show the comment that will be sent to mira in the plan of a new subnet
+	if diff.Id() == "" && diff.NewValueKnown("comment") {
+		if comment := diff.Get("comment").(string); comment != "" {
+			if err := diff.SetNew("mira_comment", client.Defaults.CommentPrefix+comment); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+// PLAN TIME POLICY: CHECK A NEW SUBNET AGAINST THE TEMPLATES AND RANGES
+// ---------------------------------------------------------------------
+
+func resourceMiraAllocatedSubnetPolicy(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	// only new subnets are checked, an existing subnet in a range denied since still plans
+	if diff.Id() != "" {
+		return nil
+	}
+
+	client := meta.(*miraclient.Client)
+
+	// a value only known at apply is checked by the client before the post instead
+	requestRange, ok := getMiraAllocatedSubnetDiffString(diff, "request_range")
+	if !ok || !diff.NewValueKnown("template") {
+		return nil
+	}
+
+	return client.Policy.CheckAssignment(diff.Get("template").(string), requestRange)
+}
+
+// -------------------------------------------------------------------------
+// PLAN TIME NAMING: GENERATE THE SUBNET NAME AND COMMENT, CHECK THE PATTERN
+// -------------------------------------------------------------------------
+
+func resourceMiraAllocatedSubnetNaming(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	client := meta.(*miraclient.Client)
+
+	// the names set in the config win over the generated ones, so look at the config itself, as
+	// the computed names of an existing subnet are in the plan whether they were configured or not
+	config := diff.GetRawConfig()
+	if config.IsNull() || !config.IsKnown() {
+		return nil
+	}
+	nameConfigured    := !config.GetAttr("subnet_name").IsNull() || !config.GetAttr("subnetname").IsNull()
+	commentConfigured := !config.GetAttr("comment").IsNull()
+
+	if !nameConfigured && !client.Naming.GeneratesSubnetName() {
+		return fmt.Errorf("subnet_name is required, unless the provider naming has a subnet_name_template")
+	}
+	if !commentConfigured && !client.Naming.GeneratesComment() {
+		return fmt.Errorf("comment is required, unless the provider naming has a comment_template")
+	}
+
+	// -----------------------------------------
+	// GENERATE WHAT IS LEFT OUT, WHEN IT CAN BE
+	// -----------------------------------------
+
+	// the inputs may come from other resources, then the generated values are only known at apply
+	var generated []string
+	if !nameConfigured {
+		generated = append(generated, "subnet_name", "subnetname")
+	}
+	if !commentConfigured {
+		generated = append(generated, "comment")
+	}
+	if !diff.NewValueKnown("naming_inputs") {
+		for _, name := range generated {
+			if err := diff.SetNewComputed(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	inputs := map[string]string{}
+	for key, value := range diff.Get("naming_inputs").(map[string]interface{}) {
+		inputs[key] = value.(string)
+	}
+
+	nameKey := "subnet_name"
+	if !nameConfigured {
+		subnetName, err := client.Naming.RenderSubnetName(inputs)
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{"subnet_name", "subnetname"} {
+			if err := diff.SetNew(name, subnetName); err != nil {
+				return err
+			}
+		}
+	} else if config.GetAttr("subnet_name").IsNull() {
+		nameKey = "subnetname"
+	}
+
+	// the pattern applies to new names, so an existing subnet with an older name still plans
+	if !diff.NewValueKnown(nameKey) {
+		return nil
+	}
+	subnetName := diff.Get(nameKey).(string)
+	if diff.Id() == "" || diff.HasChange(nameKey) {
+		if err := client.Naming.CheckSubnetName(subnetName); err != nil {
+			return err
+		}
+	}
+
+	if !commentConfigured {
+		comment, err := client.Naming.RenderComment(inputs, subnetName)
+		if err != nil {
+			return err
+		}
+		if err := diff.SetNew("comment", comment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ------------------------------------------------------------------
+// PLAN TIME CHANGE GATE: REQUIRE A TICKET FOR GATED TEMPLATES/RANGES
+// ------------------------------------------------------------------
+
+func resourceMiraAllocatedSubnetChangeGate(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	// a value only known at apply is checked by the client before the post instead
+	requestRange, ok := getMiraAllocatedSubnetDiffString(diff, "request_range")
+	if !ok || !diff.NewValueKnown("template") {
+		return nil
+	}
+
+	return checkMiraChangeGate(diff, meta.(*miraclient.Client), diff.Get("template").(string), requestRange)
+}
+
+// func to check a plan against the change gate, shared by every resource that assigns subnets. A
+// new resource is gated, and so is an existing one the plan changes, but a destroy can not be:
+// the sdk does not ask the provider to plan a destroy, so releases are gated by the client at apply
+func checkMiraChangeGate(diff *schema.ResourceDiff, client *miraclient.Client, template string, requestRange string) error {
+
+	// an existing resource the plan leaves alone needs no ticket, so refresh only plans still work
+	if diff.Id() != "" && len(diff.GetChangedKeysPrefix("")) == 0 {
+		return nil
+	}
+
+	// the ticket of the config, as the one in state is the ticket of an earlier change
+	config := diff.GetRawConfig()
+	if config.IsNull() || !config.IsKnown() || !diff.NewValueKnown("change_ticket") {
+		return nil
+	}
+	configured := !config.GetAttr("change_ticket").IsNull()
+	ticket := ""
+	if configured {
+		ticket = diff.Get("change_ticket").(string)
+	}
+
+	ticket, err := client.ChangeGate.Ticket(template, requestRange, ticket)
+	if err != nil {
+		return err
+	}
+
+	// a new resource saves the ticket taken from the environment, so the apply posts the one planned
+	if diff.Id() == "" && !configured {
+		return diff.SetNew("change_ticket", ticket)
+	}
+	return nil
+}
+
+// func to check a plan against the change gate of the mira subnet holding an address, shared by
+// the resources that change host addresses and dhcp scopes inside a subnet. A subnet only known
+// at apply is checked by the client before the change instead
+func checkMiraChangeGateForSubnet(diff *schema.ResourceDiff, client *miraclient.Client, subnetKey string) error {
+
+	// without a gate, or without a change, there is nothing to look up in mira
+	if client.ChangeGate == nil || (diff.Id() != "" && len(diff.GetChangedKeysPrefix("")) == 0) {
+		return nil
+	}
+	if !diff.NewValueKnown(subnetKey) {
+		return nil
+	}
+
+	subnetAddress := diff.Get(subnetKey).(string)
+	record, err := client.GetMiraSubnetRecordFromIPAddress(&miraclient.GetMiraSubnetFromIPAddressQueryInput{
+		IpAddress: subnetAddress,
+	})
+	if err != nil {
+		return fmt.Errorf("could not look up the subnet %s for the change gate: %w", subnetAddress, err)
+	}
+
+	return checkMiraChangeGate(diff, client, record.Template, record.Range)
+}
+
+// -------------------------------------------------------------------
+// PLAN TIME PREVIEW: PREDICT THE SUBNET A NEW RESOURCE WOULD BE GIVEN
+// -------------------------------------------------------------------
+
+func resourceMiraAllocatedSubnetPreview(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	// only new subnets are predicted, an existing subnet keeps the prediction it was assigned with
+	if diff.Id() != "" {
+		return nil
+	}
+
+	// a prediction already in the plan is never made again, so it stays the one reviewed
+	if old, _ := diff.GetChange("planned_subnet"); old.(string) != "" {
+		return nil
+	}
+	if diff.NewValueKnown("planned_subnet") && diff.Get("planned_subnet").(string) != "" {
+		return nil
+	}
+
+	client := meta.(*miraclient.Client)
+	if !client.PreviewAllocation {
+		return diff.SetNew("planned_subnet", "")
+	}
+
+	// a range or mask only known at apply can not be predicted, so the subnet stays known after apply
+	requestRange, rangeKnown := getMiraAllocatedSubnetDiffString(diff, "request_range")
+	requestMask, maskKnown := getMiraAllocatedSubnetDiffString(diff, "request_mask")
+	if !rangeKnown || !maskKnown {
+		return nil
+	}
+
+	// the resource exclusions change the subnet chosen, so they must be known too
+	if !diff.NewValueKnown("exclude_cidrs") {
+		return nil
+	}
+
+	planned, err := client.PreviewMiraSubnetAssignment(&miraclient.RangeForAvailableMiraSubnetsQueryInput{
+		RequestRange: requestRange,
+		RequestMask:  requestMask,
+		ExcludeCIDRs: expandStringList(diff.Get("exclude_cidrs").([]interface{})),
+	})
+	if err != nil {
+		// without strict mode a failed prediction only leaves the subnet known after apply
+		if client.PreviewStrict {
+			return fmt.Errorf("could not predict the subnet to assign from range %s with mask %s: %s", requestRange, requestMask, err)
+		}
+		return nil
+	}
+
+	// every new subnet from the range is predicted the same subnet, so strict mode plans one of them
+	if client.PreviewStrict {
+		subnetName, _ := getMiraAllocatedSubnetDiffString(diff, "subnet_name")
+		if err := client.ClaimStrictPreview(requestRange, subnetName); err != nil {
+			return err
+		}
+	}
+
+	return diff.SetNew("planned_subnet", planned)
+}
+
+// --------------------------------------------------------------
+// PLAN TIME CHECK THAT THE ADDRESSID IS A KNOWN LOCATION IN MIRA
+// --------------------------------------------------------------
+
+func resourceMiraAllocatedSubnetValidateAddressID(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	client := meta.(*miraclient.Client)
+	checked := map[string]bool{}
+
+	// check both names of the field, as a config may change either one while the other keeps
+	// the value in state
+	for _, name := range []string{"address_id", miraAllocatedSubnetAliases["address_id"]} {
+
+		// only check a value that is known and set, and that is new or has changed
+		if !diff.NewValueKnown(name) || (diff.Id() != "" && !diff.HasChange(name)) {
+			continue
+		}
+		addressID := diff.Get(name).(string)
+		if addressID == "" || checked[addressID] {
+			continue
+		}
+		checked[addressID] = true
+
+		// look the address id up in mira, a not found is a plan error
+		if _, err := client.GetMiraAddress(addressID); err != nil {
+			if miraclient.IsNotFound(err) {
+				return fmt.Errorf("%s %q is not a known MIRA address id", name, addressID)
+			}
+			return fmt.Errorf("could not validate %s %q against MIRA: %w", name, addressID, err)
+		}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------
+// ADOPT AN EXISTING SUBNET RECORD INTO TERRAFORM STATE
+// ----------------------------------------------------
+
+func resourceMiraAllocatedSubnetAdopt(data *schema.ResourceData, record *miraclient.MiraSubnetFoundByIPAddressResponseData) diag.Diagnostics {
+
+	// add the subnet and mask of the existing record to the resource fields
+	if err := setMiraAllocatedSubnetString(data, "assigned_subnet", record.IpAddress); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := setMiraAllocatedSubnetString(data, "assigned_subnet_mask", record.IpMask); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// same id as a subnet assigned by create
+	data.SetId(strconv.Itoa(record.RecordId))
+
+	return diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  fmt.Sprintf("Adopted existing subnet %s", record.IpAddress),
+		Detail:   fmt.Sprintf("A subnet named %q was already assigned in MIRA (record %d), so it was adopted into state instead of assigning a second one.", record.SubnetName, record.RecordId),
+	}}
+}
+
+// --------------------------------------------------------
+// TURN THE SUBNETS TRIED BY AN ASSIGNMENT INTO DIAGNOSTICS
+// --------------------------------------------------------
+
+// a failed assignment is an error listing every subnet tried, or naming the quota it would
+// exceed, an assignment that only succeeded after a subnet was taken is a warning with the
+// same list, and a dry run assignment is always a warning so the simulated subnet is not
+// mistaken for a real one
+func miraAssignmentDiagnostics(assignment *miraclient.MiraSubnetAssignmentResult, err error) diag.Diagnostics {
+	if err != nil {
+		var changeGateErr *miraclient.ChangeGateError
+		if errors.As(err, &changeGateErr) {
+			return diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "A change ticket is required",
+				Detail:   changeGateErr.Reason,
+			}}
+		}
+
+		var quotaErr *miraclient.QuotaError
+		if errors.As(err, &quotaErr) {
+			return diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Mira quota exceeded for %s", quotaErr.Quota),
+				Detail:   fmt.Sprintf("%s. Release subnets held under the quota, or raise it in the provider configuration.", quotaErr.Reason),
+			}}
+		}
+
+		var allocationErr *miraclient.AllocationError
+		if errors.As(err, &allocationErr) && len(allocationErr.Attempts) > 0 {
+			return diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "Could not assign a subnet from mira",
+				Detail:   fmt.Sprintf("%s\n\nSubnets tried: %s", allocationErr.Err, miraclient.FormatAllocationAttempts(allocationErr.Attempts)),
+			}}
+		}
+		return diag.FromErr(err)
+	}
+
+	if assignment.Simulated {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Subnet %s was simulated, not assigned", assignment.Subnet),
+			Detail:   fmt.Sprintf("The provider has dry_run set, so the subnet was chosen from the free subnets but never posted to mira. Subnets tried: %s", miraclient.FormatAllocationAttempts(assignment.Attempts)),
+		}}
+	}
+
+	if len(assignment.Attempts) > 1 {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Subnet %s was assigned after %d attempts", assignment.Subnet, len(assignment.Attempts)),
+			Detail:   fmt.Sprintf("Free subnets were taken outside this apply. Subnets tried: %s", miraclient.FormatAllocationAttempts(assignment.Attempts)),
+		}}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------
+// FREE CAPACITY CHECK, RETURNS A WARNING BELOW THE THRESHOLD
+// ----------------------------------------------------------
+
+func checkMiraRangeFreeCapacity(client *miraclient.Client, requestRange string, requestMask string, threshold int) diag.Diagnostics {
+
+	// a threshold of 0 turns the check off
+	if threshold <= 0 {
+		return nil
+	}
+
+	// get the subnets of the same size that are still free in the range
+	freeSubnets, err := client.GetAvailableSubnetsFromMiraRange(&miraclient.RangeForAvailableMiraSubnetsQueryInput{
+		RequestRange: requestRange,
+		RequestMask:  requestMask,
+	})
+	if err != nil {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Could not check the free capacity of the mira range",
+			Detail:   fmt.Sprintf("The free subnets of %s with mask %s could not be listed: %s", requestRange, requestMask, err),
+		}}
+	}
+
+	free := len(freeSubnets.Payload)
+	if free >= threshold {
+		return nil
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  "Mira range is running out of free subnets",
+		Detail:   fmt.Sprintf("Only %d subnets with mask %s are left free in range %s, below the warning threshold of %d.", free, requestMask, requestRange, threshold),
+	}}
+}
+
 func resourceMiraAllocatedSubnetUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	// use the meta value to retrieve your client from the mira provider configure method
-	// client := meta.(*apiClient)
+	client := meta.(*miraclient.Client)
+
+	// only the labels and the dhcp scope can be changed in mira, so a label drifted in mira can be put back
+	if d.HasChangesExcept("labels", "qip", "change_ticket") {
+		return diag.Errorf("not implemented, you must contact the CNE Team to change an allocation")
+	}
+
+	// a new ticket alone changes nothing in mira, and a simulated subnet has no record to change
+	if !d.HasChanges("labels", "qip") || d.Get("simulated").(bool) {
+		return nil
+	}
+
+	if d.HasChange("qip") {
+		if err := updateMiraAllocatedSubnetQip(client, d); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	if !d.HasChange("labels") {
+		return resourceMiraAllocatedSubnetRead(ctx, d, meta)
+	}
 
-	return diag.Errorf("not implemented, you must contact the CNE Team to change an allocation")
+	recordId, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return diag.Errorf("unexpected id %q, expected the mira record id of the subnet", d.Id())
+	}
+
+	labels := map[string]string{}
+	for key, value := range d.Get("labels").(map[string]interface{}) {
+		labels[key] = value.(string)
+	}
+
+	err = client.UpdateMiraSubnetLabels(&miraclient.MiraSubnetLabelsUpdateInput{
+		RecordId:     recordId,
+		Labels:       labels,
+		ChangeTicket: d.Get("change_ticket").(string),
+	})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceMiraAllocatedSubnetRead(ctx, d, meta)
+}
+
+// --------------------------------------------------------------------
+// PUT OR REMOVE THE DHCP SCOPE OF A SUBNET WHEN ITS QIP BLOCK IS CHANGED
+// --------------------------------------------------------------------
+
+// The dhcp scope is put when dhcp is set, with its new server and template, and removed when
+// dhcp is turned off. Mira can not push a subnet to qip without a scope or remove it from qip
+// after the assignment, so adding or removing the qip block alone is not implemented
+func updateMiraAllocatedSubnetQip(client *miraclient.Client, d *schema.ResourceData) error {
+	oldQip, newQip := d.GetChange("qip")
+	oldBlocks, newBlocks := oldQip.([]interface{}), newQip.([]interface{})
+
+	subnetAddress := getMiraAllocatedSubnetString(d, "assigned_subnet")
+	changeTicket := d.Get("change_ticket").(string)
+
+	// the dhcp of a block, false when the block is left out
+	dhcp := func(blocks []interface{}) bool {
+		if len(blocks) == 0 || blocks[0] == nil {
+			return false
+		}
+		return blocks[0].(map[string]interface{})["dhcp"].(bool)
+	}
+
+	switch {
+	case len(newBlocks) == 0 && len(oldBlocks) > 0:
+		return fmt.Errorf("Error: removing subnet %s from qip is not implemented, you must contact the CNE Team", subnetAddress)
+
+	case dhcp(newBlocks):
+		qip := newBlocks[0].(map[string]interface{})
+		if qip["dhcp_server"].(string) == "" {
+			return fmt.Errorf("Error: qip.dhcp_server is required when qip.dhcp is true")
+		}
+		return client.PutMiraDhcpScope(&miraclient.MiraDhcpScopeInput{
+			SubnetAddress: subnetAddress,
+			DhcpServer:    qip["dhcp_server"].(string),
+			DhcpTemplate:  qip["dhcp_template"].(string),
+			ChangeTicket:  changeTicket,
+		})
+
+	case dhcp(oldBlocks):
+		// a scope that is already gone needs no removal
+		if err := client.DeleteMiraDhcpScope(subnetAddress, changeTicket); err != nil && !miraclient.IsNotFound(err) {
+			return err
+		}
+		return nil
+
+	case len(newBlocks) > 0 && len(oldBlocks) == 0:
+		return fmt.Errorf("Error: pushing subnet %s to qip without a dhcp scope is not implemented, you must contact the CNE Team", subnetAddress)
+	}
+
+	// the server or template of a subnet without a scope is not used
+	return nil
 }
 
 func resourceMiraAllocatedSubnetDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	// use the meta value to retrieve your client from the mira provider configure method
 	// client := meta.(*apiClient)
 
+	// a subnet simulated by a dry run was never assigned, so only the state is removed
+	if d.Get("simulated").(bool) {
+		return nil
+	}
+
 	return diag.Errorf("not implemented, you must contact the CNE team to remove your allocation")
 }
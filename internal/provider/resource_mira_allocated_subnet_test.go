@@ -1,10 +1,19 @@
 package mira
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
 	"regexp"
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"terraform-provider-mira/miraclient"
 )
 
 func TestAccResourceMiraAllocatedSubnet(t *testing.T) {
@@ -30,3 +39,232 @@ resource "mira_allocated_subnet_resource" "foo" {
   sample_attribute = "bar"
 }
 `
+
+func TestMiraAssignmentDiagnostics(t *testing.T) {
+	taken := []miraclient.MiraSubnetAssignmentAttempt{
+		{Subnet: "10.0.0.0", Outcome: "mira returned a conflict"},
+		{Subnet: "10.0.0.32", Outcome: "assigned"},
+	}
+
+	// an assignment on the first try has nothing to report
+	diags := miraAssignmentDiagnostics(&miraclient.MiraSubnetAssignmentResult{Subnet: "10.0.0.0", Attempts: taken[1:]}, nil)
+	if len(diags) != 0 {
+		t.Fatalf("expected no diagnostics, got: %v", diags)
+	}
+
+	// an assignment after a retry warns with the subnets tried
+	diags = miraAssignmentDiagnostics(&miraclient.MiraSubnetAssignmentResult{Subnet: "10.0.0.32", Attempts: taken}, nil)
+	if len(diags) != 1 || diags[0].Severity != diag.Warning || !strings.Contains(diags[0].Detail, "10.0.0.0 (mira returned a conflict)") {
+		t.Fatalf("expected a warning listing the attempts, got: %v", diags)
+	}
+
+	// a dry run assignment always warns, even on the first try
+	diags = miraAssignmentDiagnostics(&miraclient.MiraSubnetAssignmentResult{Subnet: "10.0.0.0", Attempts: []miraclient.MiraSubnetAssignmentAttempt{{Subnet: "10.0.0.0", Outcome: "simulated"}}, Simulated: true}, nil)
+	if len(diags) != 1 || diags[0].Severity != diag.Warning || !strings.Contains(diags[0].Summary, "simulated") {
+		t.Fatalf("expected a warning that the subnet was simulated, got: %v", diags)
+	}
+
+	// a failed assignment errors with the subnets tried
+	err := &miraclient.AllocationError{Attempts: taken[:1], Err: errors.New("no free subnet")}
+	diags = miraAssignmentDiagnostics(nil, err)
+	if !diags.HasError() || !strings.Contains(diags[0].Detail, "10.0.0.0 (mira returned a conflict)") {
+		t.Fatalf("expected an error listing the attempts, got: %v", diags)
+	}
+
+	// an assignment refused by a quota errors with the quota
+	quota := miraclient.AssignmentQuota{AddressID: "1234567", Template: "U25_PRD_GCP", MaxCount: 4}
+	diags = miraAssignmentDiagnostics(nil, &miraclient.QuotaError{Quota: quota.String(), Reason: "4 subnets are held, and the limit is 4"})
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "address_id=1234567, template=U25_PRD_GCP") {
+		t.Fatalf("expected an error naming the quota, got: %v", diags)
+	}
+}
+
+// a fake mira for the unit tests, it answers a request with the body of the longest prefix of
+// its path and query the request matches, and with a 404 when it matches none
+type miraTestTransport map[string]string
+
+func (m miraTestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	matched := ""
+	for prefix := range m {
+		if strings.HasPrefix(req.URL.RequestURI(), prefix) && len(prefix) > len(matched) {
+			matched = prefix
+		}
+	}
+	response := &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}
+	if body, ok := m[matched]; ok && matched != "" {
+		response.StatusCode = http.StatusOK
+		response.Body = ioutil.NopCloser(strings.NewReader(body))
+	}
+	return response, nil
+}
+
+func TestMiraAllocatedSubnetPreviewIsStable(t *testing.T) {
+	client := &miraclient.Client{
+		HTTPClient: &http.Client{Transport: miraTestTransport{
+			"/":         `{"message":"OK","payload":["10.0.0.32","10.0.0.64"]}`,
+			"/address/": `{"addressID":"1234567"}`,
+		}},
+		PreviewAllocation: true,
+	}
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"address_id":    "1234567",
+		"comment":       "gke nodes",
+		"request_range": "10.0.0.0",
+		"request_mask":  "255.255.255.224",
+		"subnet_name":   "gke-nodes",
+		"template":      "U25_DEV_GCP",
+	})
+
+	// the plan made again at apply must predict the subnet the reviewed plan showed
+	var planned []string
+	for i := 0; i < 2; i++ {
+		diff, err := resourceMiraAllocatedSubnet().Diff(context.Background(), nil, config, client)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		attribute, ok := diff.Attributes["planned_subnet"]
+		if !ok || attribute.NewComputed {
+			t.Fatalf("expected a known planned_subnet, got: %v", attribute)
+		}
+		planned = append(planned, attribute.New)
+	}
+	if planned[0] != "10.0.0.32" || planned[1] != planned[0] {
+		t.Fatalf("expected 10.0.0.32 to be predicted twice, got: %v", planned)
+	}
+}
+
+func TestMiraAllocatedSubnetStrictPreviewPlansOnePerRange(t *testing.T) {
+	client := &miraclient.Client{
+		HTTPClient: &http.Client{Transport: miraTestTransport{
+			"/":         `{"message":"OK","payload":["10.1.0.32","10.1.0.64"]}`,
+			"/address/": `{"addressID":"1234567"}`,
+		}},
+		PreviewAllocation: true,
+		PreviewStrict:     true,
+	}
+	config := func(subnetName string) *terraform.ResourceConfig {
+		return terraform.NewResourceConfigRaw(map[string]interface{}{
+			"address_id":    "1234567",
+			"comment":       "gke nodes",
+			"request_range": "10.1.0.0",
+			"request_mask":  "255.255.255.224",
+			"subnet_name":   subnetName,
+			"template":      "U25_DEV_GCP",
+		})
+	}
+
+	// the same subnet planned again keeps the range, another new subnet from it fails the plan
+	for _, subnetName := range []string{"gke-nodes", "gke-nodes"} {
+		if _, err := resourceMiraAllocatedSubnet().Diff(context.Background(), nil, config(subnetName), client); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if _, err := resourceMiraAllocatedSubnet().Diff(context.Background(), nil, config("gke-pods"), client); err == nil {
+		t.Fatalf("expected a second new subnet from the range to fail the strict plan")
+	}
+}
+
+func TestMiraAllocatedSubnetValidatesChangedAddressID(t *testing.T) {
+	client := &miraclient.Client{
+		HTTPClient: &http.Client{Transport: miraTestTransport{
+			"/address/1234567": `{"addressID":"1234567"}`,
+		}},
+	}
+	state := &terraform.InstanceState{
+		ID: "4711",
+		Attributes: map[string]string{
+			"address_id":    "1234567",
+			"addressid":     "1234567",
+			"comment":       "gke nodes",
+			"request_range": "10.0.0.0",
+			"requestrange":  "10.0.0.0",
+			"request_mask":  "255.255.255.224",
+			"requestmask":   "255.255.255.224",
+			"subnet_name":   "gke-nodes",
+			"subnetname":    "gke-nodes",
+			"template":      "U25_DEV_GCP",
+		},
+	}
+
+	// the deprecated name changes while the snake_case name keeps the value in state
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"addressid":     "7654321",
+		"comment":       "gke nodes",
+		"request_range": "10.0.0.0",
+		"request_mask":  "255.255.255.224",
+		"subnet_name":   "gke-nodes",
+		"template":      "U25_DEV_GCP",
+	})
+
+	_, err := resourceMiraAllocatedSubnet().Diff(context.Background(), state, config, client)
+	if err == nil || !strings.Contains(err.Error(), `"7654321" is not a known MIRA address id`) {
+		t.Fatalf("expected the changed address id to be refused, got: %v", err)
+	}
+}
+
+func TestMiraAllocatedSubnetUpdatePutsTheChangedDhcpScope(t *testing.T) {
+	var sent []string
+	transport := miraTestTransport{
+		"/subnet/4711":    `{"address":"10.0.0.32","mask":"255.255.255.224","recordId":4711,"subnetName":"gke-nodes"}`,
+		"/qip/dhcpScope/": `{}`,
+	}
+	client := &miraclient.Client{
+		HTTPClient: &http.Client{Transport: miraRoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodGet {
+				body, _ := ioutil.ReadAll(req.Body)
+				sent = append(sent, req.Method+" "+req.URL.Path+" "+string(body))
+			}
+			return transport.RoundTrip(req)
+		})},
+	}
+	state := &terraform.InstanceState{
+		ID: "4711",
+		Attributes: map[string]string{
+			"address_id":          "1234567",
+			"comment":             "gke nodes",
+			"request_range":       "10.0.0.0",
+			"request_mask":        "255.255.255.224",
+			"subnet_name":         "gke-nodes",
+			"template":            "U25_DEV_GCP",
+			"assigned_subnet":     "10.0.0.32",
+			"qip.#":               "1",
+			"qip.0.dhcp":          "true",
+			"qip.0.dhcp_server":   "qip-dhcp-01",
+			"qip.0.dhcp_template": "",
+		},
+	}
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"address_id":    "1234567",
+		"comment":       "gke nodes",
+		"request_range": "10.0.0.0",
+		"request_mask":  "255.255.255.224",
+		"subnet_name":   "gke-nodes",
+		"template":      "U25_DEV_GCP",
+		"qip": []interface{}{map[string]interface{}{
+			"dhcp":        true,
+			"dhcp_server": "qip-dhcp-02",
+		}},
+	})
+
+	// the new dhcp server is put in place, the subnet is not assigned again
+	diff, err := resourceMiraAllocatedSubnet().Diff(context.Background(), state, config, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff.RequiresNew() {
+		t.Fatalf("expected the qip change to update the subnet in place")
+	}
+	if _, diags := resourceMiraAllocatedSubnet().Apply(context.Background(), state, diff, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], "PUT /qip/dhcpScope/10.0.0.32 ") || !strings.Contains(sent[0], `"dhcpServer":"qip-dhcp-02"`) {
+		t.Fatalf("expected the dhcp scope to be put with the new server, sent: %v", sent)
+	}
+}
+
+// a fake mira transport made from a func, eg: to record the requests sent to another one
+type miraRoundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f miraRoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,154 @@
+package mira
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	// the db mira client
+	"terraform-provider-mira/miraclient"
+)
+
+// ===========================================================
+// SCHEMA OF THE RESOURCE AT VERSION 0 [IDS WERE ADDRESS-MASK]
+// ===========================================================
+
+// the attributes in state before the id moved to the mira record id, only their types are used
+func resourceMiraAllocatedSubnetV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"addressid":                       {Type: schema.TypeString, Required: true},
+			"comment":                         {Type: schema.TypeString, Required: true},
+			"requestrange":                    {Type: schema.TypeString, Required: true},
+			"requestmask":                     {Type: schema.TypeString, Required: true},
+			"subnetname":                      {Type: schema.TypeString, Required: true},
+			"template":                        {Type: schema.TypeString, Required: true},
+			"free_capacity_warning_threshold": {Type: schema.TypeInt, Optional: true},
+			"adopt_existing":                  {Type: schema.TypeBool, Optional: true},
+			"qip": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"dhcp":          {Type: schema.TypeBool, Optional: true},
+						"dhcp_server":   {Type: schema.TypeString, Optional: true},
+						"dhcp_template": {Type: schema.TypeString, Optional: true},
+					},
+				},
+			},
+			"miraassignedsubnet":     {Type: schema.TypeString, Computed: true},
+			"miraassignedsubnetmask": {Type: schema.TypeString, Computed: true},
+			"qip_instance":           {Type: schema.TypeString, Computed: true},
+		},
+	}
+}
+
+// ==================================================================
+// STATE UPGRADE FROM VERSION 0 [LOOK UP THE RECORD ID OF THE SUBNET]
+// ==================================================================
+
+// Version 0 ids were "subnet-255.255.255.224" when set by create, and "requestrange-requestmask"
+// once read had rewritten them, in which case miraassignedsubnet had been rewritten to the range
+// as well. The mask tells the two forms apart, unless the requestmask was 255.255.255.224 too
+func resourceMiraAllocatedSubnetStateUpgradeV0(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+
+	// the records are looked up in mira, so the provider must be configured
+	client, ok := meta.(*miraclient.Client)
+	if !ok || client == nil {
+		return nil, fmt.Errorf("Error: the mira provider must be configured to upgrade the state of mira_allocated_subnet_resource")
+	}
+
+	id, _ := rawState["id"].(string)
+	address, mask, err := parseMiraAllocatedSubnetLegacyID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// -----------------------------------------------
+	// FIND THE RECORD FOR EITHER FORM OF THE LEGACY ID
+	// -----------------------------------------------
+
+	var record *miraclient.MiraSubnetFoundByIPAddressResponseData
+	if requestMask, _ := rawState["requestmask"].(string); mask != requestMask {
+		// only create put another mask than the requestmask in the id, so the id holds the
+		// assigned subnet itself, which may be the first subnet of the range
+		record, err = client.GetMiraSubnetRecordOfSubnet(&miraclient.GetMiraSubnetFromIPAddressQueryInput{
+			IpAddress: address,
+		})
+	} else {
+		// a "requestrange-requestmask" id lost the subnet, so find it by what it was assigned with,
+		// which finds it for an id of either form when the masks are the same
+		record, err = findMiraAllocatedSubnetFromState(client, rawState)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error: could not upgrade the state of subnet %s: %s", id, err)
+	}
+
+	// ---------------------------------------------
+	// SET THE RECORD ID, AND THE SUBNET IT RECORDS
+	// ---------------------------------------------
+
+	rawState["id"] = strconv.Itoa(record.RecordId)
+	rawState["miraassignedsubnet"] = record.IpAddress
+	if record.IpMask != "" {
+		rawState["miraassignedsubnetmask"] = record.IpMask
+	}
+
+	return rawState, nil
+}
+
+// ----------------------------------------------------------------
+// SPLIT A VERSION 0 ID INTO ITS ADDRESS AND MASK, ERROR IF NEITHER
+// ----------------------------------------------------------------
+
+// ip addresses never hold a "-", so the id splits on the first one
+func parseMiraAllocatedSubnetLegacyID(id string) (string, string, error) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 || net.ParseIP(parts[0]).To4() == nil || net.ParseIP(parts[1]).To4() == nil {
+		return "", "", fmt.Errorf("Error: unexpected id %q, expected <address>-<mask>", id)
+	}
+	return parts[0], parts[1], nil
+}
+
+// ---------------------------------------------------------------------
+// SEARCH FOR THE ONE SUBNET WITH THE NAME, ADDRESSID AND RANGE IN STATE
+// ---------------------------------------------------------------------
+
+func findMiraAllocatedSubnetFromState(client *miraclient.Client, rawState map[string]interface{}) (*miraclient.MiraSubnetFoundByIPAddressResponseData, error) {
+	subnetName, _ := rawState["subnetname"].(string)
+	addressID, _ := rawState["addressid"].(string)
+	requestRange, _ := rawState["requestrange"].(string)
+
+	found, err := client.SearchMiraSubnets(&miraclient.MiraSubnetSearchQueryInput{
+		SubnetName: subnetName,
+		AddressID:  addressID,
+		Range:      requestRange,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(found) != 1 {
+		return nil, fmt.Errorf("Error: found %d subnets named %q for addressid %s in range %s, expected exactly one", len(found), subnetName, addressID, requestRange)
+	}
+	return &found[0], nil
+}
+
+// =============================================================================
+// STATE UPGRADE FROM VERSION 1 [COPY EACH SQUASHED NAME TO ITS SNAKE_CASE NAME]
+// =============================================================================
+
+// Version 2 added the snake_case names, with the squashed names kept as deprecated aliases. Both
+// names hold the same value in state, so a config using either name plans no change
+func resourceMiraAllocatedSubnetStateUpgradeV1(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	for name, alias := range miraAllocatedSubnetAliases {
+		if _, ok := rawState[name]; !ok {
+			rawState[name] = rawState[alias]
+		}
+	}
+	return rawState, nil
+}
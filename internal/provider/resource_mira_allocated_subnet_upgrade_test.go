@@ -0,0 +1,110 @@
+package mira
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"terraform-provider-mira/miraclient"
+)
+
+func TestParseMiraAllocatedSubnetLegacyID(t *testing.T) {
+	cases := map[string]struct {
+		address string
+		mask    string
+		valid   bool
+	}{
+		"10.64.3.32-255.255.255.224": {"10.64.3.32", "255.255.255.224", true},
+		"10.64.0.0-255.255.192.0":    {"10.64.0.0", "255.255.192.0", true},
+		"1234":                       {"", "", false},
+		"10.64.3.32":                 {"", "", false},
+		"10.64.3.32-not-a-mask":      {"", "", false},
+	}
+
+	for id, expected := range cases {
+		address, mask, err := parseMiraAllocatedSubnetLegacyID(id)
+		if expected.valid != (err == nil) {
+			t.Fatalf("%s: expected valid %t, got error: %v", id, expected.valid, err)
+		}
+		if address != expected.address || mask != expected.mask {
+			t.Fatalf("%s: expected %s and %s, got %s and %s", id, expected.address, expected.mask, address, mask)
+		}
+	}
+}
+
+func TestResourceMiraAllocatedSubnetStateUpgradeV0Unconfigured(t *testing.T) {
+	// the upgrade looks the record up in mira, so it can not run without a client
+	rawState := map[string]interface{}{"id": "10.64.3.32-255.255.255.224"}
+	if _, err := resourceMiraAllocatedSubnetStateUpgradeV0(context.Background(), rawState, nil); err == nil {
+		t.Fatal("expected an error without a configured provider")
+	}
+}
+
+func TestResourceMiraAllocatedSubnetStateUpgradeV0(t *testing.T) {
+	// the record of the first subnet of the range, and a different subnet found by its name, so
+	// the record in the upgraded state shows which lookup was used
+	client := &miraclient.Client{
+		HTTPClient: &http.Client{Transport: miraTestTransport{
+			"/search?containsIP=10.64.0.0": `{"address":"10.64.0.0","mask":"255.255.255.224","recordId":4711}`,
+			"/search?addressID=7654321":    `[{"address":"10.64.3.32","mask":"255.255.255.224","recordId":4712}]`,
+		}},
+	}
+
+	cases := map[string]struct {
+		id       string
+		recordID string
+		subnet   string
+	}{
+		// a create id of the first subnet holds the range address, but not the requestmask
+		"create": {"10.64.0.0-255.255.255.224", "4711", "10.64.0.0"},
+		// a read id holds the requestmask, so the subnet is searched for by its name
+		"range": {"10.64.0.0-255.255.192.0", "4712", "10.64.3.32"},
+	}
+
+	for name, expected := range cases {
+		rawState := map[string]interface{}{
+			"id":                     expected.id,
+			"addressid":              "7654321",
+			"requestrange":           "10.64.0.0",
+			"requestmask":            "255.255.192.0",
+			"subnetname":             "gke-nodes",
+			"miraassignedsubnet":     "10.64.0.0",
+			"miraassignedsubnetmask": "255.255.255.224",
+		}
+
+		upgraded, err := resourceMiraAllocatedSubnetStateUpgradeV0(context.Background(), rawState, client)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		if upgraded["id"] != expected.recordID || upgraded["miraassignedsubnet"] != expected.subnet {
+			t.Fatalf("%s: expected record %s of %s, got record %v of %v", name, expected.recordID, expected.subnet, upgraded["id"], upgraded["miraassignedsubnet"])
+		}
+	}
+}
+
+func TestResourceMiraAllocatedSubnetStateUpgradeV1(t *testing.T) {
+	rawState := map[string]interface{}{
+		"id":                     "4711",
+		"addressid":              "7654321",
+		"requestrange":           "10.64.0.0",
+		"requestmask":            "255.255.192.0",
+		"subnetname":             "gke-nodes",
+		"miraassignedsubnet":     "10.64.3.32",
+		"miraassignedsubnetmask": "255.255.255.224",
+	}
+
+	upgraded, err := resourceMiraAllocatedSubnetStateUpgradeV1(context.Background(), rawState, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// every snake_case name holds the value of its alias, and the alias is kept
+	for name, alias := range miraAllocatedSubnetAliases {
+		if upgraded[name] != rawState[alias] || upgraded[alias] == nil {
+			t.Fatalf("expected %s to be %v, got %v", name, rawState[alias], upgraded[name])
+		}
+	}
+	if upgraded["id"] != "4711" {
+		t.Fatalf("expected the id to be kept, got %v", upgraded["id"])
+	}
+}
@@ -0,0 +1,176 @@
+package mira
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	// the db mira client
+	"terraform-provider-mira/miraclient"
+)
+
+// ============================================================
+// FUNCTION ASSIGNED TO RESOURCE IN provider.go [RETURN SCHEMA]
+// ============================================================
+
+func resourceMiraDhcpScope() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "A resource in the Terraform provider Mira for pushing an existing MIRA subnet to QIP with a DHCP scope. Import using the subnet address.",
+
+		// function names in this file that are assigned to CRUD calls
+		CreateContext: resourceMiraDhcpScopePut,
+		ReadContext:   resourceMiraDhcpScopeRead,
+		UpdateContext: resourceMiraDhcpScopePut,
+		DeleteContext: resourceMiraDhcpScopeDelete,
+
+		// a change to the scope of a gated subnet needs a change ticket at plan time
+		CustomizeDiff: resourceMiraDhcpScopeChangeGate,
+
+		// the id is the subnet address, which is all read needs to find the scope
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		// the resources schema map of its fields
+		Schema: map[string]*schema.Schema{
+			// populate these fields from text in terraform hcl [resources]
+			"subnet": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsIPv4Address,
+				Description:  "The address of the MIRA subnet to serve a DHCP scope for",
+			},
+			"dhcp_server": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The QIP DHCP server to serve the scope from",
+			},
+			"dhcp_template": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The QIP DHCP template to create the scope with",
+			},
+			"change_ticket": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The approved change ticket, required by the provider change_gate to change the dhcp scope of a subnet of a gated template or range, and saved with it in MIRA. Taken from the ticket_env_var of the change_gate when left out",
+			},
+			// IMPORTANT: following values are populated by the api calls to MIRA
+			"qip_instance": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The QIP instance the subnet has been pushed to",
+			},
+		},
+	}
+}
+
+// -----------------------------------------------------------------------
+// PLAN TIME CHANGE GATE: REQUIRE A TICKET FOR THE SCOPE OF A GATED SUBNET
+// -----------------------------------------------------------------------
+
+func resourceMiraDhcpScopeChangeGate(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+	return checkMiraChangeGateForSubnet(diff, meta.(*miraclient.Client), "subnet")
+}
+
+// ====================
+// CRUD CREATE / UPDATE
+// ====================
+
+func resourceMiraDhcpScopePut(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	subnet := data.Get("subnet").(string)
+
+	// the same put both creates the scope and changes its server or template
+	err := client.PutMiraDhcpScope(&miraclient.MiraDhcpScopeInput{
+		SubnetAddress: subnet,
+		DhcpServer:    data.Get("dhcp_server").(string),
+		DhcpTemplate:  data.Get("dhcp_template").(string),
+		ChangeTicket:  data.Get("change_ticket").(string),
+	})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	data.SetId(subnet)
+
+	return resourceMiraDhcpScopeRead(ctx, data, meta)
+}
+
+// =========
+// CRUD READ
+// =========
+
+func resourceMiraDhcpScopeRead(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	scope, err := client.GetMiraDhcpScope(data.Id())
+	if err != nil {
+		// the scope has been removed outside terraform, so remove it from state
+		if miraclient.IsNotFound(err) {
+			data.SetId("")
+			return diags
+		}
+		return diag.FromErr(err)
+	}
+
+	// the subnet mira holds the scope for, the id when an older mira leaves it out
+	subnet := scope.Subnet
+	if subnet == "" {
+		subnet = data.Id()
+	}
+
+	fields := map[string]interface{}{
+		"subnet":        subnet,
+		"dhcp_server":   scope.DhcpServer,
+		"dhcp_template": scope.DhcpTemplate,
+		"qip_instance":  scope.QipInstance,
+	}
+
+	// the ticket that approved the last change of a gated scope, which is saved after its comment
+	changeTicket, err := miraclient.MiraCommentChange(scope.Comment)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if changeTicket != "" {
+		fields["change_ticket"] = changeTicket
+	}
+
+	for key, value := range fields {
+		if err := data.Set(key, value); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return diags
+}
+
+// ===========
+// CRUD DELETE
+// ===========
+
+func resourceMiraDhcpScopeDelete(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	// remove the scope, one that is already gone needs no removal
+	if err := client.DeleteMiraDhcpScope(data.Id(), data.Get("change_ticket").(string)); err != nil && !miraclient.IsNotFound(err) {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
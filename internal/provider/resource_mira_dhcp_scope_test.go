@@ -0,0 +1,72 @@
+package mira
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"terraform-provider-mira/miraclient"
+)
+
+func TestAccResourceMiraDhcpScope(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceMiraDhcpScope,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"mira_dhcp_scope.foo", "dhcp_server", "qip-dhcp-01"),
+					resource.TestMatchResourceAttr(
+						"mira_dhcp_scope.foo", "qip_instance", regexp.MustCompile(".+")),
+				),
+			},
+			{
+				ResourceName:      "mira_dhcp_scope.foo",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestMiraDhcpScopeReadSetsEveryAttribute(t *testing.T) {
+	client := &miraclient.Client{
+		HTTPClient: &http.Client{Transport: miraTestTransport{
+			"/qip/dhcpScope/10.0.0.32": `{"subnet":"10.0.0.32","dhcpServer":"qip-dhcp-01","dhcpTemplate":"gke","qipInstance":"qip-eu","comment":" #change{\"ticket\":\"CHG1234567\"}"}`,
+		}},
+	}
+
+	// an imported scope has nothing but its id
+	data := schema.TestResourceDataRaw(t, resourceMiraDhcpScope().Schema, map[string]interface{}{})
+	data.SetId("10.0.0.32")
+
+	if diags := resourceMiraDhcpScopeRead(context.Background(), data, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	expected := map[string]string{
+		"subnet":        "10.0.0.32",
+		"dhcp_server":   "qip-dhcp-01",
+		"dhcp_template": "gke",
+		"qip_instance":  "qip-eu",
+		"change_ticket": "CHG1234567",
+	}
+	for key, value := range expected {
+		if got := data.Get(key).(string); got != value {
+			t.Fatalf("expected %s to be %q, got: %q", key, value, got)
+		}
+	}
+}
+
+const testAccResourceMiraDhcpScope = `
+resource "mira_dhcp_scope" "foo" {
+  subnet        = "10.10.0.0"
+  dhcp_server   = "qip-dhcp-01"
+  dhcp_template = "default"
+}
+`
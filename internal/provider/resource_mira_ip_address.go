@@ -0,0 +1,222 @@
+package mira
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	// the db mira client
+	"terraform-provider-mira/miraclient"
+)
+
+// ============================================================
+// FUNCTION ASSIGNED TO RESOURCE IN provider.go [RETURN SCHEMA]
+// ============================================================
+
+func resourceMiraIPAddress() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "A resource in the Terraform provider Mira for assigning a host address, such as a load balancer VIP or a reserved static IP, inside a MIRA subnet. Import using the host address.",
+
+		// function names in this file that are assigned to CRUD calls
+		CreateContext: resourceMiraIPAddressCreate,
+		ReadContext:   resourceMiraIPAddressRead,
+		UpdateContext: resourceMiraIPAddressUpdate,
+		DeleteContext: resourceMiraIPAddressDelete,
+
+		// a change to a host address in a gated subnet needs a change ticket at plan time
+		CustomizeDiff: resourceMiraIPAddressChangeGate,
+
+		// the id is the host address, which is all read needs to find the record
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		// the resources schema map of its fields
+		Schema: map[string]*schema.Schema{
+			// populate these fields from text in terraform hcl [resources]
+			"subnet": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsIPv4Address,
+				Description:  "The address of the MIRA subnet to assign the host address in",
+			},
+			"ip_address": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true, // the next free host address is used when this is not set
+				ForceNew:     true,
+				ValidateFunc: validation.IsIPv4Address,
+				Description:  "The host address to assign. The next free host address in the subnet is assigned when this is not set",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the host address, eg: the load balancer it is the VIP of",
+			},
+			"comment": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "A description for the host address use",
+			},
+			"change_ticket": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The approved change ticket, required by the provider change_gate to change a host address in a subnet of a gated template or range, and saved with it in MIRA. Taken from the ticket_env_var of the change_gate when left out",
+			},
+			// IMPORTANT: following values are populated by the api calls to MIRA
+			"record_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The id of the host record in MIRA",
+			},
+		},
+	}
+}
+
+// --------------------------------------------------------------------
+// PLAN TIME CHANGE GATE: REQUIRE A TICKET FOR A HOST IN A GATED SUBNET
+// --------------------------------------------------------------------
+
+func resourceMiraIPAddressChangeGate(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+	return checkMiraChangeGateForSubnet(diff, meta.(*miraclient.Client), "subnet")
+}
+
+// ===========
+// CRUD CREATE
+// ===========
+
+func resourceMiraIPAddressCreate(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// ----------
+	// GET CLIENT
+	// ----------
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	// -----------------------------------------------
+	// DO MIRA CREATE HOST ASSIGNMENT API POST REQUEST
+	// -----------------------------------------------
+
+	// assign the requested host address, or the first free one, in the subnet to this project
+	hostRecord, err := client.CreateMiraHostAssignment(&miraclient.MiraHostAssignmentPostInput{
+		SubnetAddress: data.Get("subnet").(string),
+		IpAddress:     data.Get("ip_address").(string),
+		Name:          data.Get("name").(string),
+		Comment:       data.Get("comment").(string),
+		ChangeTicket:  data.Get("change_ticket").(string),
+	})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// -----------------------------------
+	// SET RESOURCE ID TO THE HOST ADDRESS
+	// -----------------------------------
+
+	data.SetId(hostRecord.IpAddress)
+
+	// read the record back so the state matches what mira holds
+	return resourceMiraIPAddressRead(ctx, data, meta)
+}
+
+// =========
+// CRUD READ
+// =========
+
+func resourceMiraIPAddressRead(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	// -------------------------------------
+	// DO THE API REQUEST TO GET HOST RECORD
+	// -------------------------------------
+
+	hostRecord, err := client.GetMiraHostRecord(data.Id())
+	if err != nil {
+		// the host address has been released outside terraform, so remove it from state
+		if miraclient.IsNotFound(err) {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Host address %s is no longer assigned in mira", data.Id()),
+				Detail:   "The host address was released outside terraform, so it has been removed from state and will be assigned again on the next apply.",
+			})
+			data.SetId("")
+			return diags
+		}
+		return diag.FromErr(err)
+	}
+
+	// ---------------------------------------------
+	// SET TERRAFORM RESOURCE DATA WITH API RESPONSE
+	// ---------------------------------------------
+
+	// the comment without the change block saved after it
+	comment, _ := miraclient.ParseMiraComment(hostRecord.Comment)
+
+	fields := map[string]interface{}{
+		"subnet":     hostRecord.Subnet,
+		"ip_address": hostRecord.IpAddress,
+		"name":       hostRecord.Name,
+		"comment":    comment,
+		"record_id":  strconv.Itoa(hostRecord.RecordId),
+	}
+	for key, value := range fields {
+		if err := data.Set(key, value); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return diags
+}
+
+// ===========
+// CRUD UPDATE
+// ===========
+
+func resourceMiraIPAddressUpdate(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	// only the name and comment can change, every other field forces a new host address
+	err := client.UpdateMiraHostRecord(&miraclient.MiraHostUpdateInput{
+		IpAddress:    data.Id(),
+		Name:         data.Get("name").(string),
+		Comment:      data.Get("comment").(string),
+		ChangeTicket: data.Get("change_ticket").(string),
+	})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	return resourceMiraIPAddressRead(ctx, data, meta)
+}
+
+// ===========
+// CRUD DELETE
+// ===========
+
+func resourceMiraIPAddressDelete(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	// release the host address, one that is already gone needs no release
+	if err := client.DeleteMiraHostRecord(data.Id(), data.Get("change_ticket").(string)); err != nil && !miraclient.IsNotFound(err) {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
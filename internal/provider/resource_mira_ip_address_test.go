@@ -0,0 +1,48 @@
+package mira
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccResourceMiraIPAddress(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceMiraIPAddress("lb-vip"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr(
+						"mira_ip_address.foo", "ip_address", regexp.MustCompile(`^10\.10\.0\.`)),
+					resource.TestCheckResourceAttr(
+						"mira_ip_address.foo", "name", "lb-vip"),
+				),
+			},
+			{
+				Config: testAccResourceMiraIPAddress("lb-vip-renamed"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"mira_ip_address.foo", "name", "lb-vip-renamed"),
+				),
+			},
+			{
+				ResourceName:      "mira_ip_address.foo",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func testAccResourceMiraIPAddress(name string) string {
+	return `
+resource "mira_ip_address" "foo" {
+  subnet  = "10.10.0.0"
+  name    = "` + name + `"
+  comment = "acceptance test host address"
+}
+`
+}
@@ -0,0 +1,121 @@
+package mira
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	// the db mira client
+	"terraform-provider-mira/miraclient"
+)
+
+// ============================================================
+// FUNCTION ASSIGNED TO RESOURCE IN provider.go [RETURN SCHEMA]
+// ============================================================
+
+func resourceMiraJournalRelease() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "A resource in the Terraform provider Mira for releasing, at apply, the orphaned subnets the provider journal_path reports: subnets an earlier apply assigned in MIRA but stopped before saving to state. Only journal entries older than an hour are released, so the subnets of an apply that is still running are never touched. Only a subnet whose MIRA record id and address still match the journal is released, and a refresh of a resource that holds the record closes its entry, so a subnet in state is never released. A resource with adopt_existing for an orphan must be listed in depends_on, so it adopts the subnet before it can be released. Change the triggers to release again.",
+
+		// function names in this file that are assigned to CRUD calls, every field forces a new
+		// release, so there is no update, and destroying it only removes it from the state
+		CreateContext: resourceMiraJournalReleaseCreate,
+		ReadContext:   resourceMiraJournalReleaseRead,
+		DeleteContext: resourceMiraJournalReleaseDelete,
+
+		// the resources schema map of its fields
+		Schema: map[string]*schema.Schema{
+			// populate these fields from text in terraform hcl [resources]
+			"triggers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Any values, a change to them releases the orphans found again",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"change_ticket": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The approved change ticket, required by the provider change_gate to release orphans of a gated template or range",
+			},
+			// IMPORTANT: following values are populated by the api calls to MIRA
+			"released": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The orphaned subnets that were released in MIRA",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+// ===========
+// CRUD CREATE
+// ===========
+
+func resourceMiraJournalReleaseCreate(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	if client.Journal == nil {
+		return diag.Errorf("the provider has no journal_path, so there is no journal to release orphans from")
+	}
+
+	// ------------------------------------------
+	// RELEASE THE SETTLED ORPHANS IN THE JOURNAL
+	// ------------------------------------------
+
+	reconciliation, err := client.ReconcileJournal(true, data.Get("change_ticket").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// report the released subnets, and the orphans too young to be released yet
+	var diags diag.Diagnostics
+	released := make([]string, 0, len(reconciliation.Released))
+	for _, entry := range reconciliation.Released {
+		released = append(released, entry.Subnet)
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Released orphaned subnet %s", entry.Subnet),
+			Detail:   fmt.Sprintf("The subnet %q was assigned from range %s at %s but never saved to terraform state, so it was released in mira.", entry.SubnetName, entry.Range, entry.Timestamp.Format(time.RFC3339)),
+		})
+	}
+	diags = append(diags, miraJournalOrphanDiagnostics(reconciliation.Orphans)...)
+
+	// ------------------------------------------
+	// SET RESOURCE ID TO THE TIME OF THE RELEASE
+	// ------------------------------------------
+
+	data.SetId(strconv.FormatInt(time.Now().Unix(), 10))
+	if err := data.Set("released", released); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+// =========
+// CRUD READ
+// =========
+
+// the release happened once at create, so there is nothing in mira to read back
+func resourceMiraJournalReleaseRead(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return nil
+}
+
+// ===========
+// CRUD DELETE
+// ===========
+
+// released subnets can not be taken back, so only the state is removed
+func resourceMiraJournalReleaseDelete(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return nil
+}
@@ -0,0 +1,35 @@
+package mira
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccResourceMiraJournalRelease(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceMiraJournalRelease,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet(
+						"mira_journal_release.foo", "id"),
+				),
+			},
+		},
+	})
+}
+
+const testAccResourceMiraJournalRelease = `
+provider "mira" {
+  journal_path = "mira-acceptance-test.journal"
+}
+
+resource "mira_journal_release" "foo" {
+  triggers = {
+    run = "1"
+  }
+}
+`
@@ -0,0 +1,546 @@
+package mira
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	// the db mira client
+	"terraform-provider-mira/miraclient"
+)
+
+// ============================================================
+// FUNCTION ASSIGNED TO RESOURCE IN provider.go [RETURN SCHEMA]
+// ============================================================
+
+func resourceMiraSubnetGroup() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "A resource in the Terraform provider Mira for assigning several subnets of different sizes from the same range in one apply, eg: a GKE node subnet with its pod and service ranges. If any member can not be assigned, the members already assigned are released again. Members added to the map, resized, or released outside terraform are assigned in place, and members removed or resized are released once the new ones are assigned. A change to any other field, except exclude_cidrs and change_ticket, replaces the group.",
+
+		// function names in this file that are assigned to CRUD calls
+		CreateContext: resourceMiraSubnetGroupCreate,
+		ReadContext:   resourceMiraSubnetGroupRead,
+		UpdateContext: resourceMiraSubnetGroupUpdate,
+		DeleteContext: resourceMiraSubnetGroupDelete,
+
+		// every member is assigned within the one create or update timeout, and looked up within the read timeout
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Read:   schema.DefaultTimeout(2 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+		},
+
+		// checks against the provider policy that are run at plan time
+		CustomizeDiff: customdiff.All(
+			resourceMiraSubnetGroupPolicy,
+			resourceMiraSubnetGroupChangeGate,
+			resourceMiraSubnetGroupMembers,
+		),
+
+		// the resources schema map of its fields
+		Schema: map[string]*schema.Schema{
+			// populate these fields from text in terraform hcl [resources]
+			"address_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "A 7 digit integer that identifies the physical location in the world (a site id for an address)",
+			},
+			"comment": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "A description for the use of the subnets",
+			},
+			"request_range": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "!!IMPORTANT!! Mira Range from which to assign every member subnet",
+			},
+			"subnet_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The name prefix of the member subnets, each member is named `<subnet_name>-<member>`",
+			},
+			"template": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "One of the values: U25_DEV_GCP U25_UAT_GCP U25_PRD_GCP which are required preconfigured template names",
+			},
+			"members": {
+				Type:        schema.TypeMap,
+				Required:    true,
+				Description: "The member subnets to assign, as a map of member name to prefix length, eg: `{ nodes = 24, pods = 20, services = 24 }`",
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IntBetween(8, 30),
+				},
+			},
+			"change_ticket": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The approved change ticket of the assignments, required for the templates and ranges in the provider change_gate and saved with every member. Taken from the ticket_env_var of the change_gate when left out",
+			},
+			"exclude_cidrs": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Reserved blocks that MIRA still reports as free, on top of the exclude_cidrs of the provider. A free subnet overlapping any of them is never chosen for a member",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.IsCIDR,
+				},
+			},
+			// IMPORTANT: following values are populated by the api calls to MIRA
+			"subnets": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "The assigned member subnets, as a map of member name to CIDR",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"simulated": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "True when the members were chosen by a provider with dry_run set, so they were never assigned in MIRA",
+			},
+		},
+	}
+}
+
+// ===========
+// CRUD CREATE
+// ===========
+
+func resourceMiraSubnetGroupCreate(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// ----------
+	// GET CLIENT
+	// ----------
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	// ------------------------
+	// GET FIELDS FROM RESOURCE
+	// ------------------------
+
+	requestRange := data.Get("request_range").(string)
+	subnetName := data.Get("subnet_name").(string)
+
+	members := map[string]int{}
+	for name, prefixLength := range data.Get("members").(map[string]interface{}) {
+		members[name] = prefixLength.(int)
+	}
+
+	// -----------------------------------------------------
+	// ASSIGN EVERY MEMBER, ROLL BACK THE GROUP ON A FAILURE
+	// -----------------------------------------------------
+
+	subnets, assigned, diags := assignMiraSubnetGroupMembers(client, data, members, data.Timeout(schema.TimeoutCreate))
+	if diags.HasError() {
+		return diags
+	}
+
+	// -------------------------------------------------
+	// SET TERRAFORM RESOURCE DATA WITH ASSIGNED SUBNETS
+	// -------------------------------------------------
+
+	data.SetId(requestRange + "/" + subnetName)
+
+	// a dry run simulates every member, and has no journal entries to commit
+	if client.DryRun {
+		if err := data.Set("simulated", true); err != nil {
+			return diag.FromErr(err)
+		}
+		if err := data.Set("subnets", subnets); err != nil {
+			return diag.FromErr(err)
+		}
+		return diags
+	}
+
+	// every member is in state now, so close their journal entries
+	diags = append(diags, commitMiraSubnetGroupMembers(client, assigned)...)
+
+	if err := data.Set("subnets", subnets); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+// -------------------------------------------------------------------
+// ASSIGN MEMBERS, RELEASE THEM AGAIN WHEN ANY ONE CAN NOT BE ASSIGNED
+// -------------------------------------------------------------------
+
+// Assign the members, within the one timeout, and return their CIDRs by member name. When a member
+// fails, the members assigned before it are released again and the diagnostics hold an error
+func assignMiraSubnetGroupMembers(client *miraclient.Client, data *schema.ResourceData, members map[string]int, timeout time.Duration) (map[string]string, []*miraclient.MiraSubnetAssignmentResult, diag.Diagnostics) {
+	subnetName := data.Get("subnet_name").(string)
+
+	// assign the biggest members first so the smaller ones do not fragment the range,
+	// and sort by name within a size so the order is the same on every apply
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if members[names[i]] != members[names[j]] {
+			return members[names[i]] < members[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var diags diag.Diagnostics
+	subnets := map[string]string{}
+	var assigned []*miraclient.MiraSubnetAssignmentResult
+
+	// the timeout covers every member, so each member gets the time that is left
+	deadline := time.Now().Add(timeout)
+
+	// a failed member releases every member that was already assigned, and the failed member
+	// too when it was posted but could not be verified, as it may be assigned in mira
+	rollback := func(name string, err error) diag.Diagnostics {
+		failed := miraAssignmentDiagnostics(nil, err)
+		if failed[0].Detail == "" {
+			failed[0].Detail = failed[0].Summary
+		}
+		failed[0].Summary = fmt.Sprintf("Could not assign member %q of subnet group %q", name, subnetName)
+		failed = append(failed, rollbackMiraUnverifiedMember(client, err, subnetName+"-"+name, data.Get("change_ticket").(string))...)
+		return append(failed, rollbackMiraSubnetGroup(client, assigned, data.Get("change_ticket").(string))...)
+	}
+
+	for _, name := range names {
+		requestMask, err := miraclient.PrefixLengthToNetmask(members[name])
+		if err != nil {
+			return nil, nil, rollback(name, err)
+		}
+
+		timeLeft := time.Until(deadline)
+		if timeLeft <= 0 {
+			return nil, nil, rollback(name, fmt.Errorf("Error: the timeout of %s ran out before the member was assigned", timeout))
+		}
+
+		assignment, err := client.CreateMiraSubnetAssignment(&miraclient.MiraSubnetAssignmentPostInput{
+			RequestRange: data.Get("request_range").(string),
+			RequestMask:  requestMask,
+			AddressID:    data.Get("address_id").(string),
+			Comment:      data.Get("comment").(string),
+			SubnetName:   subnetName + "-" + name,
+			Template:     data.Get("template").(string),
+			ResourceType: "mira_subnet_group",
+			ChangeTicket: data.Get("change_ticket").(string),
+			ExcludeCIDRs: expandStringList(data.Get("exclude_cidrs").([]interface{})),
+			Timeout:      timeLeft,
+		})
+		if err != nil {
+			return nil, nil, rollback(name, err)
+		}
+
+		// keep any warning about subnets taken during the assignment
+		diags = append(diags, miraAssignmentDiagnostics(assignment, nil)...)
+		assigned = append(assigned, assignment)
+		subnets[name] = fmt.Sprintf("%s/%d", assignment.Subnet, members[name])
+	}
+
+	return subnets, assigned, diags
+}
+
+// func to close the journal entries of members that are in state now
+func commitMiraSubnetGroupMembers(client *miraclient.Client, assigned []*miraclient.MiraSubnetAssignmentResult) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, assignment := range assigned {
+		if err := client.Journal.Commit(assignment.JournalID); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Could not commit the assignment to the mira journal",
+				Detail:   err.Error(),
+			})
+		}
+	}
+	return diags
+}
+
+// func to compare the assigned subnets with the members, and return the members to assign, by
+// prefix length, and the names of the assigned subnets to release. A resized member is in both
+func miraSubnetGroupMemberChanges(subnets map[string]interface{}, members map[string]interface{}) (map[string]int, []string) {
+	toAssign := map[string]int{}
+	var toRelease []string
+
+	for name, prefixLength := range members {
+		cidr, ok := subnets[name]
+		if !ok || !strings.HasSuffix(cidr.(string), fmt.Sprintf("/%d", prefixLength.(int))) {
+			toAssign[name] = prefixLength.(int)
+		}
+	}
+	for name := range subnets {
+		if _, resized := toAssign[name]; resized {
+			toRelease = append(toRelease, name)
+		} else if _, ok := members[name]; !ok {
+			toRelease = append(toRelease, name)
+		}
+	}
+
+	sort.Strings(toRelease)
+	return toAssign, toRelease
+}
+// ------------------------------------------------------------------
+// RELEASE THE MEMBERS ASSIGNED BEFORE A FAILURE, RETURN ANY LEFTOVER
+// ------------------------------------------------------------------
+
+func rollbackMiraSubnetGroup(client *miraclient.Client, assigned []*miraclient.MiraSubnetAssignmentResult, changeTicket string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, assignment := range assigned {
+		// a simulated member was never posted, so there is nothing to release
+		if assignment.Simulated {
+			continue
+		}
+		if err := client.DeleteMiraSubnetAssignment(assignment.Subnet, changeTicket); err != nil {
+			// the journal entry stays pending, so the subnet is reported again when the provider starts
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Could not roll back subnet %s", assignment.Subnet),
+				Detail:   fmt.Sprintf("The subnet is assigned in mira but not in terraform, you must contact the CNE team to remove it: %s", err),
+			})
+			continue
+		}
+
+		// the subnet is released, so an entry left pending would only be aborted when the provider starts
+		if err := client.Journal.Abort(assignment.JournalID); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Could not abort the journal entry of rolled back subnet %s", assignment.Subnet),
+				Detail:   err.Error(),
+			})
+		}
+	}
+	return diags
+}
+
+// func to release the member that failed, when it was posted but its assignment could not be verified
+func rollbackMiraUnverifiedMember(client *miraclient.Client, err error, memberName string, changeTicket string) diag.Diagnostics {
+	var allocationErr *miraclient.AllocationError
+	if !errors.As(err, &allocationErr) || allocationErr.Unverified == nil {
+		return nil
+	}
+
+	if err := client.ReleaseUnverifiedMiraSubnetAssignment(allocationErr.Unverified, memberName, changeTicket); err != nil {
+		// the journal entry stays pending, so the subnet is reported again when the provider starts
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Could not roll back subnet %s", allocationErr.Unverified.Subnet),
+			Detail:   fmt.Sprintf("The subnet was posted to mira but its assignment could not be verified, so it may be assigned in mira but not in terraform, you must contact the CNE team to check it: %s", err),
+		}}
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------
+// PLAN TIME POLICY: CHECK A NEW GROUP AGAINST THE TEMPLATES AND RANGES
+// --------------------------------------------------------------------
+
+func resourceMiraSubnetGroupPolicy(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	// only new groups are checked, and a value only known at apply is checked by the client instead
+	if diff.Id() != "" || !diff.NewValueKnown("request_range") || !diff.NewValueKnown("template") {
+		return nil
+	}
+
+	client := meta.(*miraclient.Client)
+	return client.Policy.CheckAssignment(diff.Get("template").(string), diff.Get("request_range").(string))
+}
+
+// ------------------------------------------------------------------
+// PLAN TIME CHANGE GATE: REQUIRE A TICKET FOR A GATED TEMPLATE/RANGE
+// ------------------------------------------------------------------
+
+func resourceMiraSubnetGroupChangeGate(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	// a value only known at apply is checked by the client before the post instead
+	if !diff.NewValueKnown("request_range") || !diff.NewValueKnown("template") {
+		return nil
+	}
+
+	return checkMiraChangeGate(diff, meta.(*miraclient.Client), diff.Get("template").(string), diff.Get("request_range").(string))
+}
+
+// =========
+// CRUD READ
+// =========
+
+func resourceMiraSubnetGroupRead(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// Warning or errors can be collected in a slice type
+	var diags diag.Diagnostics
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	// members simulated by a dry run are not in mira, so there is nothing to check, and once the
+	// dry run is turned off the group is removed from state, so the next apply assigns it for real
+	if data.Get("simulated").(bool) {
+		if !client.DryRun {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "The subnet group was only simulated by a dry run",
+				Detail:   "The provider no longer has dry_run set, so the simulated group has been removed from state and will be assigned on the next apply.",
+			})
+			data.SetId("")
+		}
+		return diags
+	}
+
+	// check every member subnet still has a record of its own in mira, a member that was released
+	// would otherwise be found as the record of its range
+	subnets := map[string]interface{}{}
+	for name, cidr := range data.Get("subnets").(map[string]interface{}) {
+		subnetAddress := strings.SplitN(cidr.(string), "/", 2)[0]
+
+		returnedSubnet, err := client.GetMiraSubnetRecordOfSubnet(&miraclient.GetMiraSubnetFromIPAddressQueryInput{
+			IpAddress: subnetAddress,
+			Timeout:   data.Timeout(schema.TimeoutRead),
+		})
+		if miraclient.IsNotFound(err) {
+			// the member is left out of subnets, so the plan shows it is assigned again
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Member %q of the subnet group is no longer assigned in mira", name),
+				Detail:   fmt.Sprintf("The subnet %s was released outside terraform, so it has been removed from state and will be assigned again on the next apply.", cidr),
+			})
+			continue
+		} else if err != nil {
+			return diag.FromErr(err)
+		}
+		subnets[name] = cidr
+
+		// the member is in state, so a journal entry left open by a lost commit is closed, and never released
+		if err := client.Journal.CommitRecord(returnedSubnet.RecordId); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Could not commit the subnet group member to the mira journal",
+				Detail:   err.Error(),
+			})
+		}
+	}
+
+	if err := data.Set("subnets", subnets); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diags
+}
+
+// ===========
+// CRUD UPDATE
+// ===========
+
+// The members added, resized or released outside terraform are assigned, and the members removed
+// or resized are released once the new ones are assigned. Every other field forces a new group
+func resourceMiraSubnetGroupUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	// use the meta value to retrieve your client from the mira provider configure method
+	client := meta.(*miraclient.Client)
+
+	// the members of a group are all simulated, or all assigned in mira
+	simulated := d.Get("simulated").(bool)
+	if client.DryRun && !simulated {
+		return diag.Errorf("the members of subnet group %q are assigned in mira, so they can not be changed by a provider with dry_run set", d.Get("subnet_name").(string))
+	}
+
+	oldSubnets, _ := d.GetChange("subnets")
+	toAssign, toRelease := miraSubnetGroupMemberChanges(oldSubnets.(map[string]interface{}), d.Get("members").(map[string]interface{}))
+
+	subnets := map[string]interface{}{}
+	for name, cidr := range oldSubnets.(map[string]interface{}) {
+		subnets[name] = cidr
+	}
+
+	// ---------------------------------------------------------------
+	// ASSIGN THE NEW MEMBERS, ROLL THEM BACK WHEN ANY ONE CAN NOT BE
+	// ---------------------------------------------------------------
+
+	assignedSubnets, assigned, diags := assignMiraSubnetGroupMembers(client, d, toAssign, d.Timeout(schema.TimeoutUpdate))
+	if diags.HasError() {
+		// nothing has changed in mira, so the state keeps the members it had
+		if err := d.Set("subnets", subnets); err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+		return diags
+	}
+	diags = append(diags, commitMiraSubnetGroupMembers(client, assigned)...)
+
+	// ------------------------------------------------
+	// RELEASE THE MEMBERS REMOVED OR REPLACED BY A SIZE
+	// ------------------------------------------------
+
+	for _, name := range toRelease {
+		cidr := subnets[name].(string)
+		delete(subnets, name)
+
+		// a simulated member was never assigned, so there is nothing to release
+		if simulated {
+			continue
+		}
+		if err := client.DeleteMiraSubnetAssignment(strings.SplitN(cidr, "/", 2)[0], d.Get("change_ticket").(string)); err != nil && !miraclient.IsNotFound(err) {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Could not release subnet %s of member %q", cidr, name),
+				Detail:   fmt.Sprintf("The subnet is still assigned in mira: %s", err),
+			})
+
+			// a removed member stays in state, so the next apply tries to release it again
+			if _, resized := toAssign[name]; !resized {
+				subnets[name] = cidr
+			}
+		}
+	}
+
+	for name, cidr := range assignedSubnets {
+		subnets[name] = cidr
+	}
+	if err := d.Set("subnets", subnets); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	return diags
+}
+
+// -------------------------------------------------------------------------
+// PLAN TIME MEMBERS: SHOW THE SUBNETS CHANGE WHEN A MEMBER MUST BE ASSIGNED
+// -------------------------------------------------------------------------
+
+// a member added, resized, removed or released outside terraform changes the subnets of the group
+func resourceMiraSubnetGroupMembers(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+	if diff.Id() == "" {
+		return nil
+	}
+	if !diff.NewValueKnown("members") {
+		return diff.SetNewComputed("subnets")
+	}
+
+	oldSubnets, _ := diff.GetChange("subnets")
+	toAssign, toRelease := miraSubnetGroupMemberChanges(oldSubnets.(map[string]interface{}), diff.Get("members").(map[string]interface{}))
+	if len(toAssign) > 0 || len(toRelease) > 0 {
+		return diff.SetNewComputed("subnets")
+	}
+	return nil
+}
+
+func resourceMiraSubnetGroupDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// members simulated by a dry run were never assigned, so only the state is removed
+	if d.Get("simulated").(bool) {
+		return nil
+	}
+	return diag.Errorf("not implemented, you must contact the CNE team to remove your allocation")
+}
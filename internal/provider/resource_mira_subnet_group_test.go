@@ -0,0 +1,88 @@
+package mira
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"terraform-provider-mira/miraclient"
+)
+
+func TestAccResourceMiraSubnetGroup(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceMiraSubnetGroup,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"mira_subnet_group.foo", "subnets.%", "3"),
+					resource.TestMatchResourceAttr(
+						"mira_subnet_group.foo", "subnets.pods", regexp.MustCompile(`/20$`)),
+					resource.TestMatchResourceAttr(
+						"mira_subnet_group.foo", "subnets.nodes", regexp.MustCompile(`/24$`)),
+				),
+			},
+		},
+	})
+}
+
+func TestMiraSubnetGroupReadDropsReleasedMembers(t *testing.T) {
+	client := &miraclient.Client{
+		HTTPClient: &http.Client{Transport: miraTestTransport{
+			"/search?containsIP=10.64.0.0": `{"address":"10.64.0.0","recordId":4711,"subnetName":"dev-gke-euw3-01-pods"}`,
+		}},
+	}
+	data := schema.TestResourceDataRaw(t, resourceMiraSubnetGroup().Schema, map[string]interface{}{
+		"members": map[string]interface{}{"pods": 20, "nodes": 24},
+	})
+	data.SetId("10.64.0.0/dev-gke-euw3-01")
+	if err := data.Set("subnets", map[string]interface{}{"pods": "10.64.0.0/20", "nodes": "10.64.16.0/24"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the nodes member was released outside terraform, so it is left out to be assigned again
+	diags := resourceMiraSubnetGroupRead(context.Background(), data, client)
+	if diags.HasError() || len(diags) != 1 {
+		t.Fatalf("expected one warning, got: %v", diags)
+	}
+	if subnets := data.Get("subnets").(map[string]interface{}); !reflect.DeepEqual(subnets, map[string]interface{}{"pods": "10.64.0.0/20"}) {
+		t.Fatalf("expected only the pods member to be left, got: %v", subnets)
+	}
+}
+
+func TestMiraSubnetGroupMemberChanges(t *testing.T) {
+	subnets := map[string]interface{}{"nodes": "10.64.16.0/24", "pods": "10.64.0.0/20", "services": "10.64.17.0/24"}
+	members := map[string]interface{}{"nodes": 24, "pods": 19, "proxy": 26}
+
+	// a new member is assigned, a resized one is assigned and released, a removed one is released
+	toAssign, toRelease := miraSubnetGroupMemberChanges(subnets, members)
+	if !reflect.DeepEqual(toAssign, map[string]int{"pods": 19, "proxy": 26}) {
+		t.Fatalf("unexpected members to assign: %v", toAssign)
+	}
+	if !reflect.DeepEqual(toRelease, []string{"pods", "services"}) {
+		t.Fatalf("unexpected members to release: %v", toRelease)
+	}
+}
+
+const testAccResourceMiraSubnetGroup = `
+resource "mira_subnet_group" "foo" {
+  address_id    = "7654321"
+  comment       = "acceptance test gke cluster"
+  request_range = "10.64.0.0"
+  subnet_name   = "dev-gke-euw3-01"
+  template      = "U25_DEV_GCP"
+
+  members = {
+    nodes    = 24
+    pods     = 20
+    services = 24
+  }
+}
+`
@@ -0,0 +1,180 @@
+package miraclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// an address id is a 7 digit integer that identifies a physical location
+var addressIDPattern = regexp.MustCompile(`^[0-9]{7}$`)
+
+// ***************************************************************************
+// CREATE INPUT AND OUTPUT STRUCTS FOR: GetMiraAddress and SearchMiraAddresses
+// ***************************************************************************
+
+// the site details mira holds for an address id
+type MiraAddressRecord struct {
+	AddressID string `json:"addressID"`
+	Country   string `json:"country"`
+	Region    string `json:"region"`
+	City      string `json:"city"`
+	Street    string `json:"street"`
+	Building  string `json:"building"`
+	SiteName  string `json:"siteName"`
+}
+
+// the filters for a reverse search of addresses, empty fields are not filtered on
+type MiraAddressSearchQueryInput struct {
+	Country string
+	Region  string
+}
+
+// ==================================================================================
+// METHOD: GetMiraAddress [REQUEST SITE DETAILS BY ADDRESS ID, RETURN ADDRESS RECORD]
+// ==================================================================================
+
+// Create a http request, add authentication details and the address id to look up
+func (c *Client) GetMiraAddress(addressID string) (*MiraAddressRecord, error) {
+
+	// -----------
+	// CHECK INPUT
+	// -----------
+
+	// check the address id is a 7 digit integer before asking mira for it
+	if !addressIDPattern.MatchString(addressID) {
+		return nil, fmt.Errorf("Error: %s is not a 7 digit address id, in GetMiraAddress", addressID)
+	}
+
+	// -------------------
+	// CREATE HTTP REQUEST
+	// -------------------
+
+	// create MIRA address lookup url, using the address id
+	getAddressURL := fmt.Sprintf(baseURL+"address/%s", addressID)
+	method := "GET"
+
+	// create a new get request object for the url above
+	getAddressReq, err := http.NewRequest(method, getAddressURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// ----------------
+	// SET AUTH HEADERS
+	// ----------------
+
+	// set auth header
+	getAddressReq.SetBasicAuth(c.Username, c.Password)
+	// set content to json
+	getAddressReq.Header.Set("Content-Type", "application/json")
+
+	// -------------------
+	// DO MIRA API REQUEST
+	// -------------------
+
+	// do http request and return a string of the body text
+	getAddressRespBody, err := c.doRequest(getAddressReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// --------------------
+	// UNMARSHAL JSON BYTES
+	// --------------------
+
+	// create a struct for the api response body bytes
+	var unmarshaledResponseData MiraAddressRecord
+
+	// unmarshal the data from the response body json bytes into struct
+	err = json.Unmarshal(getAddressRespBody, &unmarshaledResponseData)
+	if err != nil {
+		return nil, err
+	}
+
+	// ----------------
+	// RETURN BODY DATA
+	// ----------------
+
+	return &unmarshaledResponseData, nil
+}
+
+// ======================================================================================
+// METHOD: SearchMiraAddresses [REQUEST ADDRESSES BY COUNTRY/REGION, RETURN ADDRESS LIST]
+// ======================================================================================
+
+// Create a http request, add authentication details and the country and region to search for
+func (c *Client) SearchMiraAddresses(queryInput *MiraAddressSearchQueryInput) ([]MiraAddressRecord, error) {
+
+	// -----------
+	// CHECK INPUT
+	// -----------
+
+	// an unfiltered search would return every site in mira
+	if queryInput.Country == "" && queryInput.Region == "" {
+		return nil, fmt.Errorf("Error: a country or a region is required, in SearchMiraAddresses")
+	}
+
+	// -------------------
+	// CREATE HTTP REQUEST
+	// -------------------
+
+	// only add the filters that were provided to the query string
+	query := url.Values{}
+	if queryInput.Country != "" {
+		query.Set("country", queryInput.Country)
+	}
+	if queryInput.Region != "" {
+		query.Set("region", queryInput.Region)
+	}
+
+	// create MIRA address search url, using the query string above
+	searchURL := baseURL + "address/search?" + query.Encode()
+	method := "GET"
+
+	// create a new get request object for the url above
+	searchAddressReq, err := http.NewRequest(method, searchURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// ----------------
+	// SET AUTH HEADERS
+	// ----------------
+
+	// set auth header
+	searchAddressReq.SetBasicAuth(c.Username, c.Password)
+	// set content to json
+	searchAddressReq.Header.Set("Content-Type", "application/json")
+
+	// -------------------
+	// DO MIRA API REQUEST
+	// -------------------
+
+	// do http request and return a string of the body text
+	searchAddressRespBody, err := c.doRequest(searchAddressReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// --------------------
+	// UNMARSHAL JSON BYTES
+	// --------------------
+
+	// create a slice for the api response body bytes
+	var unmarshaledResponseData []MiraAddressRecord
+
+	// unmarshal the data from the response body json bytes into the slice
+	err = json.Unmarshal(searchAddressRespBody, &unmarshaledResponseData)
+	if err != nil {
+		return nil, err
+	}
+
+	// ----------------
+	// RETURN BODY DATA
+	// ----------------
+
+	return unmarshaledResponseData, nil
+}
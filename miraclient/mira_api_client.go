@@ -11,11 +11,42 @@ import (
 	"time"
 	"bytes"
 	"strings"
+	"sync"
 )
 
 const baseURL   string = "http://10.156.0.3/"
 const userAgent string = "terraform-provider-mira"
 
+// the number of free subnets tried by an assignment before it gives up, unless configured
+const DefaultMaxAllocationAttempts int = 3
+
+// one lock per mira range, shared by every client in this provider process (including
+// aliased provider configurations) so parallel creates never choose the same free subnet.
+// The locks are channels with room for one holder, so waiting for them can time out
+var rangeLocks sync.Map
+
+// one lock for the quotas, shared the same way, as a quota can count the subnets of many
+// ranges, so two assignments from different ranges could otherwise both fit the same quota
+var quotaLock = make(chan struct{}, 1)
+
+// one lock per subnet, shared the same way, so parallel host assignments in a subnet never
+// choose the same free host address
+var hostLocks sync.Map
+
+// the subnets chosen by dry run assignments in this provider process, which mira still returns
+// as free as nothing was posted, so later dry run assignments choose other subnets
+var simulatedSubnets struct {
+	sync.Mutex
+	networks []net.IPNet
+}
+
+// the name of the one new subnet each range may be strictly predicted for in this provider
+// process, as every new subnet from a range is predicted the same first free subnet
+var strictPreviews struct {
+	sync.Mutex
+	subnetNames map[string]string
+}
+
 // **************************
 // CREATE A NEW CLIENT STRUCT
 // **************************
@@ -27,6 +58,46 @@ type Client struct {
 	UserAgent  string
 	URL        string
 	HTTPClient *http.Client
+
+	// the number of free subnets an assignment tries when they are taken by someone else
+	MaxAllocationAttempts int
+
+	// the write ahead journal of assignment posts, nil when it is turned off
+	Journal *Journal
+
+	// the owner stamped on every new subnet, nil when stamping is turned off
+	Ownership *Ownership
+
+	// the naming conventions checked at plan time, nil when there are none
+	Naming *NamingConvention
+
+	// the values filled in at plan time for the fields a resource leaves out
+	Defaults SubnetDefaults
+
+	// the named ranges a resource can give by name, keyed by their name
+	RangeCatalog map[string]CatalogRange
+
+	// the templates and ranges this provider may assign from, checked before every post
+	Policy AssignmentPolicy
+
+	// the caps on the subnets held, counted with a search before every post
+	Quotas []AssignmentQuota
+
+	// the templates and ranges that need a change ticket, nil when there is no gate
+	ChangeGate *ChangeGate
+
+	// the blocks mira reports as free that are reserved, so never chosen, eg: interconnects and DR
+	ExcludeCIDRs []string
+
+	// refuse every request that would change mira, for plan only pipelines with read only credentials
+	ReadOnly bool
+
+	// choose subnets as an assignment would, but never post them
+	DryRun bool
+
+	// predict the subnet of every new assignment at plan time, and in strict mode assign only that subnet
+	PreviewAllocation bool
+	PreviewStrict     bool
 }
 
 // =========================================
@@ -58,6 +129,7 @@ func NewClient() (*Client, error) {
 		UserAgent: userAgent,
 		Username:  username,
 		Password:  password,
+		MaxAllocationAttempts: DefaultMaxAllocationAttempts,
 	}
 
 	// return a pointer to the client
@@ -85,6 +157,13 @@ func checkIPAddress(ip string) bool {
 // the http reqest and returns the body bytes
 func (c *Client) doRequest(req *http.Request) ([]byte, error) {
 
+	// the mutating methods refuse first with a clearer error, this catches any that do not
+	if req.Method != http.MethodGet {
+		if err := c.checkWritable(req.Method + " " + req.URL.Path); err != nil {
+			return nil, err
+		}
+	}
+
 	// use the http client to 'do' the request
 	res, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -100,13 +179,137 @@ func (c *Client) doRequest(req *http.Request) ([]byte, error) {
 
 	// return error if status was not http:200
 	if res.StatusCode != http.StatusOK {
-		return  nil, fmt.Errorf("status: %d, body: %s", res.StatusCode, body)
+		return  nil, &StatusError{StatusCode: res.StatusCode, Body: body}
 	}
 
 	// give body of response to calling function
 	return body, nil
 }
 
+// =================================================================================
+// METHOD: doRequestWithTimeout [DO HTTP REQUEST WITH ITS OWN TIMEOUT, RETURN BYTES]
+// =================================================================================
+
+// Do a http request that may take longer than the client timeout allows, eg: searchFreeSubnet
+// on a large range. A timeout of zero keeps the timeout of the client
+func (c *Client) doRequestWithTimeout(req *http.Request, timeout time.Duration) ([]byte, error) {
+	if timeout <= 0 {
+		return c.doRequest(req)
+	}
+
+	// copy the http client, so other requests keep the client timeout
+	httpClient := *c.HTTPClient
+	httpClient.Timeout = timeout
+	timed := *c
+	timed.HTTPClient = &httpClient
+	return timed.doRequest(req)
+}
+
+// func to get the time left before a deadline, zero when there is no deadline
+func timeLeft(deadline time.Time) (time.Duration, error) {
+	if deadline.IsZero() {
+		return 0, nil
+	}
+	left := time.Until(deadline)
+	if left <= 0 {
+		return 0, fmt.Errorf("Error: the timeout was reached before the request to mira was sent")
+	}
+	return left, nil
+}
+
+// =======================================================================
+// METHOD: newMiraRequest [CREATE HTTP REQUEST WITH AUTH AND JSON HEADERS]
+// =======================================================================
+
+// Create a http request for a mira url, with the auth and json content headers set
+func (c *Client) newMiraRequest(method string, url string, body []byte) (*http.Request, error) {
+
+	// create a new request object for the url, only post and put requests carry a body
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = http.NewRequest(method, url, bytes.NewBuffer(body))
+	} else {
+		req, err = http.NewRequest(method, url, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// set auth header
+	req.SetBasicAuth(c.Username, c.Password)
+	// set content to json
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
+
+// -----------------------------------------------------
+// ERROR TYPE FOR A CHANGE REFUSED BY A READ ONLY CLIENT
+// -----------------------------------------------------
+
+// the error returned for a change to mira while the provider is read only
+type ReadOnlyError struct {
+	Operation string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("Error: the mira provider is read_only, %s was refused without contacting mira", e.Operation)
+}
+
+// func to test if an error is a change refused by a read only client
+func IsReadOnly(err error) bool {
+	var readOnlyErr *ReadOnlyError
+	return errors.As(err, &readOnlyErr)
+}
+
+// the error returned for a change to mira that a dry run can not simulate
+type DryRunError struct {
+	Operation string
+}
+
+func (e *DryRunError) Error() string {
+	return fmt.Sprintf("Error: the mira provider is a dry_run, %s was refused without contacting mira, only subnet assignments are simulated", e.Operation)
+}
+
+// func to refuse a change to mira when the client is read only, or a dry run. A dry run
+// simulates the subnet assignments itself, so it only gets here for the changes it can not
+func (c *Client) checkWritable(operation string) error {
+	if c.ReadOnly {
+		return &ReadOnlyError{Operation: operation}
+	}
+	if c.DryRun {
+		return &DryRunError{Operation: operation}
+	}
+	return nil
+}
+
+// --------------------------------------------------
+// ERROR TYPE FOR A NON 200 STATUS RETURNED FROM MIRA
+// --------------------------------------------------
+
+// the status code and body of a mira response that was not http:200
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status: %d, body: %s", e.StatusCode, e.Body)
+}
+
+// func to test if an error is mira saying it has no such record
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// func to test if an error is mira saying the record already exists, eg: a subnet assigned by someone else
+func IsConflict(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict
+}
+
 // *********************************************************************
 // CREATE INPUT AND OUTPUT STRUCTS FOR: GetAvailableSubnetsFromMiraRange
 // *********************************************************************
@@ -115,12 +318,15 @@ func (c *Client) doRequest(req *http.Request) ([]byte, error) {
 type RangeForAvailableMiraSubnetsQueryInput struct {
 	RequestRange string
 	RequestMask  string
+	Timeout      time.Duration // zero keeps the client timeout
+	ExcludeCIDRs []string      // reserved blocks left out, on top of the ExcludeCIDRs of the client
 }
 
 // A list of subnets from MIRA that are available for use 
 type AvailableSubnetsResponseFromMira struct {
 	Message      string   `json:"message"`
 	Payload      []string `json:"payload"`
+	Excluded     []string `json:"-"` // free subnets left out of the payload, as they overlap an excluded CIDR
 }
 
 // =================================================================================================
@@ -173,7 +379,7 @@ func (c *Client) GetAvailableSubnetsFromMiraRange(miraRange *RangeForAvailableMi
 	// --------------
 
 	// do http request (func above this one) and return a string of the body text
-	freeSubnetRespBody, err := c.doRequest(freeSubnetReq)
+	freeSubnetRespBody, err := c.doRequestWithTimeout(freeSubnetReq, miraRange.Timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -208,10 +414,21 @@ func (c *Client) GetAvailableSubnetsFromMiraRange(miraRange *RangeForAvailableMi
 	for index, element := range freeSubnetsList {
 		// check that the element is actaully an IP
 		if !(checkIPAddress(element)) {
-			return nil, fmt.Errorf("Error: %s is not Subnet, at position %d mira free subnets payload %d", element, index, freeSubnetsList)
+			return nil, fmt.Errorf("Error: %s is not Subnet, at position %d mira free subnets payload %s", element, index, freeSubnetsList)
 		}
 	}
 
+	// -------------------------------------------
+	// LEAVE OUT THE SUBNETS IN THE RESERVED CIDRS
+	// -------------------------------------------
+
+	// mira reports the informally reserved blocks as free, so every caller leaves them out here
+	excludeCIDRs := append(append([]string{}, c.ExcludeCIDRs...), miraRange.ExcludeCIDRs...)
+	unmarshaledResponseData.Payload, unmarshaledResponseData.Excluded, err = excludeMiraSubnets(freeSubnetsList, miraRange.RequestMask, excludeCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
 	// --------------------
 	// RETURN GOOD RESPONSE
 	// --------------------
@@ -232,6 +449,16 @@ type MiraSubnetAssignmentPostInput struct {
 	Comment           string
 	SubnetName        string
 	Template          string
+	AlsoQip           bool
+	Dhcp              bool
+	DhcpServer        string
+	DhcpTemplate      string
+	Labels            map[string]string // saved in a block after the comment, see mira_comment.go
+	ResourceType      string            // the terraform resource assigning the subnet, stamped with the owner
+	ExpectedSubnet    string            // the subnet predicted at plan time, tried first while it is still free
+	ChangeTicket      string            // the approved change, required by the change gate and saved after the comment
+	ExcludeCIDRs      []string          // reserved blocks never chosen, on top of the ExcludeCIDRs of the client
+	Timeout           time.Duration // the whole assignment, including any wait for mira, zero keeps the client timeout
 }
 
 // input from MiraSubnetAssignmentPostInput and static values 
@@ -265,11 +492,73 @@ type MiraSubnetAssignmentPostData struct {
 	Vlan              string `json:"vlan"`
 }
 
+// the response to an assignment post, mira answers a slow assignment with a pending status
+type MiraSubnetAssignmentPostResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// the states of an assignment record while mira is still working on it, and once it is done
+// (older mira versions answer without a status, so an empty status is done)
+var assignmentPendingStates = []string{"pending", "queued", "job"}
+var assignmentTargetStates  = []string{"active", ""}
+
+// what happened to one free subnet that was tried during an assignment
+type MiraSubnetAssignmentAttempt struct {
+	Subnet  string
+	Outcome string
+}
+
+// the subnet assigned by CreateMiraSubnetAssignment, and every subnet tried to get it
+type MiraSubnetAssignmentResult struct {
+	Subnet    string
+	RecordId  int
+	Attempts  []MiraSubnetAssignmentAttempt
+	JournalID string // commit this once the subnet is saved to terraform state
+	Simulated bool   // chosen by a dry run, so never posted and without a record
+}
+
+// the error returned when an assignment fails, with every subnet tried before the failure
+type AllocationError struct {
+	Attempts []MiraSubnetAssignmentAttempt
+	Err      error
+
+	// the subnet posted last, when its assignment could not be verified, so it may be assigned in mira
+	Unverified *MiraSubnetAssignmentResult
+}
+
+func (e *AllocationError) Error() string {
+	if len(e.Attempts) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s, attempts: %s", e.Err, FormatAllocationAttempts(e.Attempts))
+}
+
+func (e *AllocationError) Unwrap() error {
+	return e.Err
+}
+
+// func to list the subnets tried during an assignment on one line, eg: "10.0.0.0 (mira returned a conflict), 10.0.0.32 (assigned)"
+func FormatAllocationAttempts(attempts []MiraSubnetAssignmentAttempt) string {
+	var formatted []string
+	for _, attempt := range attempts {
+		formatted = append(formatted, fmt.Sprintf("%s (%s)", attempt.Subnet, attempt.Outcome))
+	}
+	return strings.Join(formatted, ", ")
+}
+
 // ===================================================================================
 // METHOD: CreateMiraSubnetAssignment [REQUEST SUBNETS ASSIGNMENT, RETURN HTTP STATUS]
 // ===================================================================================
 
-func (c *Client) CreateMiraSubnetAssignment(postInput *MiraSubnetAssignmentPostInput) (string, error) {
+func (c *Client) CreateMiraSubnetAssignment(postInput *MiraSubnetAssignmentPostInput) (*MiraSubnetAssignmentResult, error) {
+
+	// refuse before anything is sent, when the provider is read only (a dry run changes nothing)
+	if !c.DryRun {
+		if err := c.checkWritable("the subnet assignment"); err != nil {
+			return nil, err
+		}
+	}
 
 	// -----------------------------------------------------
 	// PUT INPUT STRUCT INTO INDIVIDUAL VARS FOR READABILITY
@@ -278,10 +567,7 @@ func (c *Client) CreateMiraSubnetAssignment(postInput *MiraSubnetAssignmentPostI
 	// get input data required for api query from terrafrom resource (provided by module)
 	mirarange  := postInput.RequestRange
 	rangemask  := postInput.RequestMask
-	addressID  := postInput.AddressID
-	comment	   := postInput.Comment
 	subnetname := postInput.SubnetName
-	template   := postInput.Template
 
 
 	// ---------------
@@ -290,12 +576,58 @@ func (c *Client) CreateMiraSubnetAssignment(postInput *MiraSubnetAssignmentPostI
 
 	// check that the range to be supplied to mira is in ip address format
 	if !(checkIPAddress(mirarange)) {
-		return "", fmt.Errorf("Error: %s is not valid mira range", mirarange)
+		return nil, fmt.Errorf("Error: %s is not valid mira range", mirarange)
 	}
 
 	// check that the range mask to be supplied to mira is in ip address format
 	if !(checkIPAddress(rangemask)) {
-		return "", fmt.Errorf("Error: %s is not a valid mira mask", rangemask)
+		return nil, fmt.Errorf("Error: %s is not a valid mira mask", rangemask)
+	}
+
+	// check the policy again, in case the plan time check was skipped, eg: for a value only known at apply
+	if err := c.Policy.CheckAssignment(postInput.Template, mirarange); err != nil {
+		return nil, err
+	}
+
+	// a gated template or range needs a change ticket, which may come from the environment
+	changeTicket, err := c.ChangeGate.Ticket(postInput.Template, mirarange, postInput.ChangeTicket)
+	if err != nil {
+		return nil, err
+	}
+	gatedInput := *postInput
+	gatedInput.ChangeTicket = changeTicket
+	postInput = &gatedInput
+
+	// -----------------------------------------------------
+	// LOCK THE RANGE UNTIL THE ASSIGNMENT HAS BEEN VERIFIED
+	// -----------------------------------------------------
+
+	// the timeout covers the wait for the locks and every request and wait below
+	var deadline time.Time
+	if postInput.Timeout > 0 {
+		deadline = time.Now().Add(postInput.Timeout)
+	}
+
+	// hold the locks across the count, query, choose, post and verify steps below, the quota
+	// lock is always taken first, so two assignments never wait on each other
+	unlockQuotas, err := c.lockQuotas(deadline)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockQuotas()
+	unlockRange, err := c.lockRange(mirarange, deadline)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockRange()
+
+	// --------------------------------------------------
+	// COUNT THE SUBNETS HELD AGAINST THE PROVIDER QUOTAS
+	// --------------------------------------------------
+
+	// counted while the quotas are locked, so parallel assignments can not both fit the last place
+	if err := c.checkQuotas(postInput); err != nil {
+		return nil, err
 	}
 
 	// -------------------------------------------
@@ -303,15 +635,22 @@ func (c *Client) CreateMiraSubnetAssignment(postInput *MiraSubnetAssignmentPostI
 	// -------------------------------------------
 
 	// add inpput vars to query input struct
+	// the query gets what is left of the timeout, as the quotas were counted first
+	queryTimeout, err := timeLeft(deadline)
+	if err != nil {
+		return nil, err
+	}
 	rangeForAvailableSubnets := RangeForAvailableMiraSubnetsQueryInput{
 		RequestRange: mirarange,
 		RequestMask: rangemask,
+		Timeout: queryTimeout,
+		ExcludeCIDRs: postInput.ExcludeCIDRs,
 	}
 
 	// get free subnets from mira range, from api endpoint
 	unmarshaledResponseData, err := c.GetAvailableSubnetsFromMiraRange(&rangeForAvailableSubnets)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// --------------------------------------------
@@ -321,31 +660,200 @@ func (c *Client) CreateMiraSubnetAssignment(postInput *MiraSubnetAssignmentPostI
 	// add the response payload (a list of available subnets) to the terraform resource
 	freeSubnetsList := unmarshaledResponseData.Payload
 
+	// every free subnet may be reserved, which needs another range or fewer exclusions, not a retry
+	if len(freeSubnetsList) == 0 && len(unmarshaledResponseData.Excluded) > 0 {
+		return nil, fmt.Errorf("Error: every free subnet with mask %s in range %s overlaps an excluded CIDR, the subnets left out were: %s", rangemask, mirarange, strings.Join(unmarshaledResponseData.Excluded, ", "))
+	}
+
 	// check the api response contained a list of available subnets
 	if (len(freeSubnetsList) == 0) {
-		return "", fmt.Errorf("Error: mira api returned an empty subnet array: [ %s ]", freeSubnetsList)
+		return nil, fmt.Errorf("Error: mira api returned an empty subnet array: [ %s ]", freeSubnetsList)
+	}
+
+	// ------------------------------------------------
+	// TRY THE SUBNET PREDICTED AT PLAN TIME BEFORE ANY
+	// ------------------------------------------------
+
+	// the subnet predicted at plan time is what the plan was reviewed with, so it is assigned
+	// while it is still free. In strict mode it is the only subnet that may be assigned
+	if postInput.ExpectedSubnet != "" {
+		freeSubnetsList, err = preferExpectedSubnet(freeSubnetsList, postInput.ExpectedSubnet, c.PreviewStrict)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// a dry run stops here, with the subnet the loop below would have tried first
+	if c.DryRun {
+		return c.simulateMiraSubnetAssignment(freeSubnetsList, rangemask, mirarange)
 	}
 
 	// ===========================================================================
 	// FOR LOOP TO HERE FROM DO API - repeat using next range if subnet list is 0
 	// ===========================================================================
 
-	// ------------------------------------------
-	// CHOOSE SUBNET FROM FREE SUBNETS API OUTPUT
-	// ------------------------------------------
+	// ----------------------------------------------------------
+	// TRY THE FREE SUBNETS IN ORDER UNTIL ONE IS ASSIGNED TO US
+	// ----------------------------------------------------------
+
+	// every subnet tried and what happened to it, returned with the result or the error
+	var attempts []MiraSubnetAssignmentAttempt
+
+	for _, chosenSubnet := range freeSubnetsList {
+
+		// give up after the configured number of attempts
+		if len(attempts) >= c.maxAllocationAttempts() {
+			break
+		}
+
+		// check that the chosen IP is actaully an IP... just for good measure
+		if !(checkIPAddress(chosenSubnet)) {
+			return nil, &AllocationError{Attempts: attempts, Err: fmt.Errorf("Error: %s is not Subnet, but was about to be submitted to mira", chosenSubnet)}
+		}
+
+		// --------------------------------
+		// DO POST REQUEST TO ASSIGN SUBNET
+		// --------------------------------
+
+		// write the intent to the journal first, so the subnet can be found again if we are killed after the post
+		journalID, err := c.Journal.Intent(chosenSubnet, rangemask, mirarange, subnetname)
+		if err != nil {
+			return nil, &AllocationError{Attempts: attempts, Err: err}
+		}
+
+		timeout, err := timeLeft(deadline)
+		if err != nil {
+			return nil, &AllocationError{Attempts: attempts, Err: err}
+		}
+
+		postStatus, postErr := c.postMiraSubnetAssignment(postInput, chosenSubnet, timeout)
+
+		// a conflict means the subnet was taken since the free subnets query, so try the next one
+		if IsConflict(postErr) {
+			attempts = append(attempts, MiraSubnetAssignmentAttempt{Subnet: chosenSubnet, Outcome: "mira returned a conflict"})
+			if err := c.Journal.Abort(journalID); err != nil {
+				return nil, &AllocationError{Attempts: attempts, Err: err}
+			}
+			continue
+		}
+
+		// ----------------------------------------
+		// VERIFY THE ASSIGNMENT BY READING IT BACK
+		// ----------------------------------------
+
+		// the post status is not trusted on its own, the subnet must now have a record in mira,
+		// and when mira is still working on the assignment we wait until the record is active
+		timeout, err = timeLeft(deadline)
+		if err != nil {
+			return nil, &AllocationError{Attempts: attempts, Err: err, Unverified: &MiraSubnetAssignmentResult{Subnet: chosenSubnet, JournalID: journalID}}
+		}
+		returnedSubnet, err := c.waitForMiraSubnetRecord(chosenSubnet, postErr == nil && containsString(assignmentPendingStates, postStatus), timeout)
+		if err != nil {
+			return nil, &AllocationError{
+				Attempts:   attempts,
+				Err:        fmt.Errorf("Error: the assignment of %s could not be verified: %s (post error: %v)", chosenSubnet, err, postErr),
+				Unverified: &MiraSubnetAssignmentResult{Subnet: chosenSubnet, JournalID: journalID},
+			}
+		}
+		// the record belongs to someone else, so the subnet was taken and the next one is tried
+		if returnedSubnet.SubnetName != "" && returnedSubnet.SubnetName != subnetname {
+			attempts = append(attempts, MiraSubnetAssignmentAttempt{Subnet: chosenSubnet, Outcome: fmt.Sprintf("already assigned to %q", returnedSubnet.SubnetName)})
+			if err := c.Journal.Abort(journalID); err != nil {
+				return nil, &AllocationError{Attempts: attempts, Err: err}
+			}
+			continue
+		}
+
+		attempts = append(attempts, MiraSubnetAssignmentAttempt{Subnet: chosenSubnet, Outcome: "assigned"})
+
+		// the record id is what a release of the subnet as an orphan is matched on
+		if err := c.Journal.Posted(journalID, returnedSubnet.RecordId); err != nil {
+			return nil, &AllocationError{Attempts: attempts, Err: err}
+		}
+
+		// IMPORTANT if there was no error the subnet is now assigned in mira but not in terraform
+		return &MiraSubnetAssignmentResult{
+			Subnet:    chosenSubnet,
+			RecordId:  returnedSubnet.RecordId,
+			Attempts:  attempts,
+			JournalID: journalID,
+		}, nil
+	}
+
+	// every candidate tried was taken, or the attempt limit was reached
+	return nil, &AllocationError{Attempts: attempts, Err: fmt.Errorf("Error: no free subnet in range %s could be assigned after %d attempts", mirarange, len(attempts))}
+}
+
+// ======================================================================================
+// METHOD: waitForMiraSubnetRecord [READ A SUBNET RECORD, WAIT WHILE IT IS STILL PENDING]
+// ======================================================================================
+
+// Read the record of a subnet that has just been posted. When mira answered the post as
+// pending the record is polled until it is active, as it may not exist yet or be half done
+func (c *Client) waitForMiraSubnetRecord(subnetAddress string, pending bool, timeout time.Duration) (*MiraSubnetFoundByIPAddressResponseData, error) {
+
+	queryInput := &GetMiraSubnetFromIPAddressQueryInput{
+		IpAddress: subnetAddress,
+		Timeout:   timeout,
+	}
+
+	// mira finished the assignment within the post, so one read is enough
+	if !pending {
+		return c.GetMiraSubnetRecordOfSubnet(queryInput)
+	}
 
-	// choose the first available subnet in the list
-	chosenSubnet := freeSubnetsList[0]
+	waiter := &miraStateWaiter{
+		Pending: assignmentPendingStates,
+		Target:  assignmentTargetStates,
+		Timeout: timeout,
+		Refresh: func() (interface{}, string, error) {
+			record, err := c.GetMiraSubnetRecordOfSubnet(queryInput)
+			// no record of the subnet yet, only of its range, means mira has not got to the assignment
+			if IsNotFound(err) {
+				return nil, "pending", nil
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			return record, record.Status, nil
+		},
+	}
 
-	// check that the chosen IP is actaully an IP... just for good measure
-	if !(checkIPAddress(chosenSubnet)) {
-		return "", fmt.Errorf("Error: %s is not Subnet, but was about to be submitted to mira", chosenSubnet)
+	record, err := waiter.WaitForState()
+	if err != nil {
+		return nil, err
 	}
+	return record.(*MiraSubnetFoundByIPAddressResponseData), nil
+}
+
+// ===================================================================================
+// METHOD: postMiraSubnetAssignment [POST ONE CHOSEN SUBNET ASSIGNMENT, RETURN STATUS]
+// ===================================================================================
+
+func (c *Client) postMiraSubnetAssignment(postInput *MiraSubnetAssignmentPostInput, chosenSubnet string, timeout time.Duration) (string, error) {
+
+	// get input data required for api query from terrafrom resource (provided by module)
+	mirarange  := postInput.RequestRange
+	rangemask  := postInput.RequestMask
+	addressID  := postInput.AddressID
+	comment	   := postInput.Comment
+	subnetname := postInput.SubnetName
+	template   := postInput.Template
 
 	// -------------------------
 	// PREPARE POST REQUEST DATA
 	// -------------------------
 
+	// mira has no labels, so they are saved in a block after the human comment
+	comment, err := EncodeMiraComment(comment, map[string]interface{}{
+		CommentBlockLabels: postInput.Labels,
+		CommentBlockOwner:  c.Ownership.stamp(postInput.ResourceType),
+		CommentBlockChange: changeStamp(postInput.ChangeTicket),
+	})
+	if err != nil {
+		return "", err
+	}
+
 	// split ipv4 subnet address and mask into individual strings per octet 
 	var ipoctets []string = strings.Split(chosenSubnet, ".")
 	var nmoctets []string = strings.Split(rangemask, ".")
@@ -358,12 +866,12 @@ func (c *Client) CreateMiraSubnetAssignment(postInput *MiraSubnetAssignmentPostI
 	// Encode the data for the post, from a struct to json
 	postBody, err := json.Marshal(MiraSubnetAssignmentPostData{
 		AddressID: addressID,		// 7 digit ID for physical location, can be prepopulated: eg: all locations eu-region3 get "765431"
-		AlsoQip: false,			// always false
+		AlsoQip: postInput.AlsoQip,	// true when the subnet should also be pushed to qip
 		Building: "",			// always empty
 		Comments: comment,		// comment field from resource, populated with the subnets purpose
-		Dhcp: false,			// always false
-		DhcpServer: "",			// always empty
-		DhcpTemplate: "",		// always empty
+		Dhcp: postInput.Dhcp,		// true when qip should serve a dhcp scope for the subnet
+		DhcpServer: postInput.DhcpServer,	// the qip dhcp server, empty without dhcp
+		DhcpTemplate: postInput.DhcpTemplate,	// the qip dhcp template, empty without dhcp
 		Floor: "",			// always empty
 		Ip1: ipoctets[0],		// first octect
 		Ip2: ipoctets[1],		// second octet
@@ -408,13 +916,222 @@ func (c *Client) CreateMiraSubnetAssignment(postInput *MiraSubnetAssignmentPostI
 	// --------------------------------
 
 	// do http post request to assign the subnet
-	_, err = c.doRequest(assignSubnetReq)
+	assignSubnetRespBody, err := c.doRequestWithTimeout(assignSubnetReq, timeout)
+	if err != nil {
+		return "", err
+	}
+
+	// a body that is not json carries no status, so the assignment is treated as done
+	var postResponse MiraSubnetAssignmentPostResponse
+	if err := json.Unmarshal(assignSubnetRespBody, &postResponse); err != nil {
+		return "", nil
+	}
+	return postResponse.Status, nil
+}
+
+// ========================================================================================
+// METHOD: simulateMiraSubnetAssignment [CHOOSE A FREE SUBNET FOR A DRY RUN, NEVER POST IT]
+// ========================================================================================
+
+// Choose the first free subnet that no earlier simulated assignment overlaps, as
+// nothing was posted for those they are still in the free subnets list mira returns
+func (c *Client) simulateMiraSubnetAssignment(freeSubnetsList []string, rangemask string, mirarange string) (*MiraSubnetAssignmentResult, error) {
+	simulatedSubnets.Lock()
+	defer simulatedSubnets.Unlock()
+
+	var attempts []MiraSubnetAssignmentAttempt
+	mask := net.IPMask(net.ParseIP(rangemask).To4())
+
+	for _, chosenSubnet := range freeSubnetsList {
+		if !(checkIPAddress(chosenSubnet)) {
+			return nil, &AllocationError{Attempts: attempts, Err: fmt.Errorf("Error: %s is not Subnet, but was about to be submitted to mira", chosenSubnet)}
+		}
+		candidate := net.IPNet{IP: net.ParseIP(chosenSubnet).To4(), Mask: mask}
+
+		if overlapsAny(candidate, simulatedSubnets.networks) {
+			attempts = append(attempts, MiraSubnetAssignmentAttempt{Subnet: chosenSubnet, Outcome: "chosen by an earlier simulated assignment"})
+			continue
+		}
+
+		simulatedSubnets.networks = append(simulatedSubnets.networks, candidate)
+		attempts = append(attempts, MiraSubnetAssignmentAttempt{Subnet: chosenSubnet, Outcome: "simulated"})
+		return &MiraSubnetAssignmentResult{
+			Subnet:    chosenSubnet,
+			Attempts:  attempts,
+			Simulated: true,
+		}, nil
+	}
+
+	return nil, &AllocationError{Attempts: attempts, Err: fmt.Errorf("Error: every free subnet in range %s was chosen by an earlier simulated assignment", mirarange)}
+}
+
+// func to move the expected subnet to the front of the free subnets, in strict mode the free
+// subnets are only the expected subnet, and it is an error when it is no longer free
+func preferExpectedSubnet(freeSubnetsList []string, expectedSubnet string, strict bool) ([]string, error) {
+	if !containsString(freeSubnetsList, expectedSubnet) {
+		if strict {
+			return nil, fmt.Errorf("Error: the subnet %s predicted at plan time is no longer free, plan again to predict another subnet", expectedSubnet)
+		}
+		return freeSubnetsList, nil
+	}
+	if strict {
+		return []string{expectedSubnet}, nil
+	}
+
+	preferred := []string{expectedSubnet}
+	for _, freeSubnet := range freeSubnetsList {
+		if freeSubnet != expectedSubnet {
+			preferred = append(preferred, freeSubnet)
+		}
+	}
+	return preferred, nil
+}
+
+// ==========================================================================================
+// METHOD: PreviewMiraSubnetAssignment [RETURN THE SUBNET AN ASSIGNMENT WOULD CHOOSE, OR ERR]
+// ==========================================================================================
+
+// Predict the subnet an assignment would choose at plan time, without changing mira. The
+// prediction only depends on what mira returns, so the plan made again at apply predicts the
+// same subnet while mira is unchanged. New subnets from one range are all predicted the same
+// first free subnet, as keeping earlier predictions would only work within one process
+func (c *Client) PreviewMiraSubnetAssignment(queryInput *RangeForAvailableMiraSubnetsQueryInput) (string, error) {
+
+	if !(checkIPAddress(queryInput.RequestRange)) {
+		return "", fmt.Errorf("Error: %s is not valid mira range", queryInput.RequestRange)
+	}
+	if !(checkIPAddress(queryInput.RequestMask)) {
+		return "", fmt.Errorf("Error: %s is not a valid mira mask", queryInput.RequestMask)
+	}
+
+	unmarshaledResponseData, err := c.GetAvailableSubnetsFromMiraRange(queryInput)
 	if err != nil {
-		// return "", err // NEVER USE THIS IN PRODUCTION WHILE COMMENTED - ONLY TEST
+		return "", err
+	}
+
+	// the free subnets are checked to be ips, and the excluded ones left out, by the query
+	if len(unmarshaledResponseData.Payload) > 0 {
+		return unmarshaledResponseData.Payload[0], nil
+	}
+
+	return "", fmt.Errorf("Error: mira has no free subnet with mask %s in range %s left to predict", queryInput.RequestMask, queryInput.RequestRange)
+}
+
+// =============================================================================================
+// METHOD: ClaimStrictPreview [RESERVE THE STRICT PREDICTION OF A RANGE FOR ONE NEW SUBNET NAME]
+// =============================================================================================
+
+// Claim a range for the one new subnet a plan may strictly predict from it. Every new subnet from
+// a range is predicted the same first free subnet, whatever its mask, so with preview_strict all
+// but one of them would fail at apply. A second subnet name is refused, so the plan fails instead.
+// The same subnet planned again, eg: at apply, keeps its claim. The claim only decides whether a
+// plan is refused, the prediction itself still only depends on mira
+func (c *Client) ClaimStrictPreview(requestRange string, subnetName string) error {
+	strictPreviews.Lock()
+	defer strictPreviews.Unlock()
+
+	if strictPreviews.subnetNames == nil {
+		strictPreviews.subnetNames = map[string]string{}
+	}
+	if claimed, ok := strictPreviews.subnetNames[requestRange]; ok && (claimed != subnetName || subnetName == "") {
+		return fmt.Errorf("Error: with preview_strict a plan can only assign one new subnet from range %s, and %q is already planned from it. Apply the other subnets from the range in a later plan, or turn preview_strict off", requestRange, claimed)
+	}
+	strictPreviews.subnetNames[requestRange] = subnetName
+	return nil
+}
+
+// func to split free subnets into the ones kept and the ones overlapping an excluded CIDR
+func excludeMiraSubnets(freeSubnets []string, mask string, excludeCIDRs []string) ([]string, []string, error) {
+	if len(excludeCIDRs) == 0 {
+		return freeSubnets, nil, nil
+	}
+
+	var excludedNetworks []net.IPNet
+	for _, cidr := range excludeCIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error: %s is not a valid CIDR to exclude: %s", cidr, err)
+		}
+		excludedNetworks = append(excludedNetworks, *network)
+	}
+
+	kept := []string{}
+	var excluded []string
+	subnetMask := net.IPMask(net.ParseIP(mask).To4())
+	for _, freeSubnet := range freeSubnets {
+		if overlapsAny(net.IPNet{IP: net.ParseIP(freeSubnet).To4(), Mask: subnetMask}, excludedNetworks) {
+			excluded = append(excluded, freeSubnet)
+			continue
+		}
+		kept = append(kept, freeSubnet)
+	}
+	return kept, excluded, nil
+}
+
+// func to test if a subnet overlaps any of a list of subnets
+func overlapsAny(subnet net.IPNet, networks []net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(subnet.IP) || subnet.Contains(network.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// func to get the attempt limit of an assignment, never less than one attempt
+func (c *Client) maxAllocationAttempts() int {
+	if c.MaxAllocationAttempts < 1 {
+		return 1
+	}
+	return c.MaxAllocationAttempts
+}
+
+// ----------------------------------------------------------------
+// RANGE AND QUOTA LOCKS FOR USE IN ALL METHODS THAT ASSIGN SUBNETS
+// ----------------------------------------------------------------
+
+// lock a mira range for this provider process, and return the func that unlocks it. The lock
+// is keyed by the range alone, not by the range and mask: a /24 and a /26 from the same range
+// can be the same free block, so with a lock per mask, assignments of different sizes would
+// still collide. Assignments from one range are serialised, whatever their mask
+func (c *Client) lockRange(mirarange string, deadline time.Time) (func(), error) {
+	lock, _ := rangeLocks.LoadOrStore(mirarange, make(chan struct{}, 1))
+	return acquireLock(lock.(chan struct{}), deadline, "range "+mirarange)
+}
+
+// lock the quotas for this provider process until an assignment has been posted, and return
+// the func that unlocks them. Without quotas nothing is counted, so nothing is locked
+func (c *Client) lockQuotas(deadline time.Time) (func(), error) {
+	if len(c.Quotas) == 0 {
+		return func() {}, nil
+	}
+	return acquireLock(quotaLock, deadline, "the quotas")
+}
+
+// func to wait for a lock until the deadline, and return the func that unlocks it. Without a
+// deadline it waits for as long as the lock is held
+func acquireLock(lock chan struct{}, deadline time.Time, locked string) (func(), error) {
+	unlock := func() { <-lock }
+
+	// take a free lock at once, even when the deadline has passed
+	select {
+	case lock <- struct{}{}:
+		return unlock, nil
+	default:
+	}
+	if deadline.IsZero() {
+		lock <- struct{}{}
+		return unlock, nil
 	}
 
-	// IMPORTANT if there was no error the subnet is now assigned in mira but not in terraform
-	return chosenSubnet, nil
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case lock <- struct{}{}:
+		return unlock, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("Error: the timeout was reached while waiting for %s, which is locked by another assignment in this provider", locked)
+	}
 }
 
 // *********************************************************************
@@ -424,6 +1141,7 @@ func (c *Client) CreateMiraSubnetAssignment(postInput *MiraSubnetAssignmentPostI
 // the subnet address, submitted as just an ip
 type GetMiraSubnetFromIPAddressQueryInput struct {
 	IpAddress  string `json:"ipaddress"`
+	Timeout    time.Duration `json:"-"` // zero keeps the client timeout
 }
 
 // the record from mira for the subnet from whence the ip came
@@ -440,6 +1158,12 @@ type MiraSubnetFoundByIPAddressResponseData struct {
 	SubnetClass	string `json:"subnetClass"`
 	Tenant		string `json:"tenant"`
 	QipInstance	string `json:"qipInstance"`
+	SubnetName	string `json:"subnetName"`
+	AddressID	string `json:"addressID"`
+	Range		string `json:"range"`
+	Template	string `json:"template"`
+	Comments	string `json:"comments"`
+	Status		string `json:"status"`
 }
 
 // ========================================================================================================
@@ -454,7 +1178,7 @@ func (c *Client) GetMiraSubnetRecordFromIPAddress(queryInput *GetMiraSubnetFromI
 	// -------------------
 
 	// create MIRA search subnet by address string, using the subnet address and cidr mask
-	url := fmt.Sprintf(baseURL+ "search?containsIP=", queryInput.IpAddress)
+	url := fmt.Sprintf(baseURL+"search?containsIP=%s", queryInput.IpAddress)
 	method := "GET"
 
 	// create a new get request object for the url above
@@ -477,7 +1201,7 @@ func (c *Client) GetMiraSubnetRecordFromIPAddress(queryInput *GetMiraSubnetFromI
 	// -------------------
 
 	// do http request and return a string of the body text
-	getSubnetByIpReqBody, err := c.doRequest(getSubnetByIpReq)
+	getSubnetByIpReqBody, err := c.doRequestWithTimeout(getSubnetByIpReq, queryInput.Timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -503,3 +1227,219 @@ func (c *Client) GetMiraSubnetRecordFromIPAddress(queryInput *GetMiraSubnetFromI
 	return &unmarshaledResponseData, nil
 }
 
+// ===========================================================================================
+// METHOD: GetMiraSubnetRecordOfSubnet [REQUEST THE RECORD OF THE SUBNET ITSELF, OR NOT FOUND]
+// ===========================================================================================
+
+// search?containsIP returns the record of the range for an address that has no record of its
+// own, so a record for another address is returned as not found, the same as no record at all
+func (c *Client) GetMiraSubnetRecordOfSubnet(queryInput *GetMiraSubnetFromIPAddressQueryInput) (*MiraSubnetFoundByIPAddressResponseData, error) {
+	returnedSubnet, err := c.GetMiraSubnetRecordFromIPAddress(queryInput)
+	if err != nil {
+		return nil, err
+	}
+	if returnedSubnet.IpAddress != queryInput.IpAddress {
+		return nil, &StatusError{StatusCode: http.StatusNotFound, Body: []byte(fmt.Sprintf("no record for %s, mira returned the record of %s", queryInput.IpAddress, returnedSubnet.IpAddress))}
+	}
+	return returnedSubnet, nil
+}
+
+// ****************************************************************
+// CREATE INPUT STRUCT FOR: GetMiraSubnetRecordByID (RECORD OUTPUT)
+// ****************************************************************
+
+// the record id of an assigned subnet, as returned in its record
+type GetMiraSubnetByIDQueryInput struct {
+	RecordId int
+	Timeout  time.Duration // zero keeps the client timeout
+}
+
+// ===================================================================================
+// METHOD: GetMiraSubnetRecordByID [REQUEST SUBNET RECORD BY ID, RETURN SUBNET RECORD]
+// ===================================================================================
+
+// Get the record of an assigned subnet by its record id, which unlike the subnet address never
+// matches the record of the range it was assigned from
+func (c *Client) GetMiraSubnetRecordByID(queryInput *GetMiraSubnetByIDQueryInput) (*MiraSubnetFoundByIPAddressResponseData, error) {
+
+	getSubnetByIDReq, err := c.newMiraRequest("GET", fmt.Sprintf(baseURL+"subnet/%d", queryInput.RecordId), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// do http request and return a string of the body text
+	getSubnetByIDRespBody, err := c.doRequestWithTimeout(getSubnetByIDReq, queryInput.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// unmarshal the data from the response body json bytes into struct
+	var unmarshaledResponseData MiraSubnetFoundByIPAddressResponseData
+	err = json.Unmarshal(getSubnetByIDRespBody, &unmarshaledResponseData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &unmarshaledResponseData, nil
+}
+
+
+// =======================================================================================
+// METHOD: ReleaseUnverifiedMiraSubnetAssignment [RELEASE A POSTED SUBNET WHEN IT IS OURS]
+// =======================================================================================
+
+// Release the subnet of an assignment whose post could not be verified, eg: when it is rolled
+// back with the rest of a group. It is only released when mira has a record of it with our
+// subnet name, as the post may have failed and the subnet be free or assigned to someone else
+func (c *Client) ReleaseUnverifiedMiraSubnetAssignment(unverified *MiraSubnetAssignmentResult, subnetName string, changeTicket string) error {
+
+	returnedSubnet, err := c.GetMiraSubnetRecordOfSubnet(&GetMiraSubnetFromIPAddressQueryInput{
+		IpAddress: unverified.Subnet,
+	})
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+
+	// the subnet is ours, so it is released like any other assignment
+	if err == nil && returnedSubnet.SubnetName == subnetName {
+		if err := c.DeleteMiraSubnetAssignment(unverified.Subnet, changeTicket); err != nil {
+			return err
+		}
+	}
+
+	// the subnet is not assigned to us in mira now, so its journal entry is closed
+	return c.Journal.Abort(unverified.JournalID)
+}
+
+// =====================================================================================
+// METHOD: DeleteMiraSubnetAssignment [RELEASE AN ASSIGNED SUBNET BY ITS SUBNET ADDRESS]
+// =====================================================================================
+
+// Look up the record of an assigned subnet and ask mira to release it, a subnet of a gated
+// template or range is only released with a change ticket
+func (c *Client) DeleteMiraSubnetAssignment(subnetAddress string, changeTicket string) error {
+
+	// refuse before anything is sent, when the provider is read only
+	if err := c.checkWritable("the release of subnet " + subnetAddress); err != nil {
+		return err
+	}
+
+	// -----------
+	// CHECK INPUT
+	// -----------
+
+	// check that the subnet to be released is in ip address format
+	if !(checkIPAddress(subnetAddress)) {
+		return fmt.Errorf("Error: %s is not a valid mira subnet, in DeleteMiraSubnetAssignment", subnetAddress)
+	}
+
+	// ----------------------------------------
+	// GET THE RECORD ID OF THE ASSIGNED SUBNET
+	// ----------------------------------------
+
+	// the record of the subnet itself, so the record of its range is never released instead
+	returnedSubnet, err := c.GetMiraSubnetRecordOfSubnet(&GetMiraSubnetFromIPAddressQueryInput{
+		IpAddress: subnetAddress,
+	})
+	if err != nil {
+		return err
+	}
+
+	// the release of a gated subnet needs a change ticket, as its assignment did
+	if _, err := c.ChangeGate.Ticket(returnedSubnet.Template, returnedSubnet.Range, changeTicket); err != nil {
+		return err
+	}
+
+	// ---------------------------------------
+	// DO DELETE REQUEST TO RELEASE THE SUBNET
+	// ---------------------------------------
+
+	deleteSubnetReq, err := c.newMiraRequest("DELETE", fmt.Sprintf(baseURL+"subnet/%d", returnedSubnet.RecordId), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.doRequest(deleteSubnetReq)
+	return err
+}
+
+// *************************************************************
+// CREATE INPUT AND PUT DATA STRUCTS FOR: UpdateMiraSubnetLabels
+// *************************************************************
+
+type MiraSubnetLabelsUpdateInput struct {
+	RecordId     int
+	Labels       map[string]string
+	ChangeTicket string // required by the change gate when the subnet is gated
+}
+
+// the only field of an assigned subnet the provider changes in mira
+type MiraSubnetCommentsPutData struct {
+	Comments string `json:"comments"`
+}
+
+// =====================================================================================
+// METHOD: UpdateMiraSubnetLabels [REWRITE THE LABELS BLOCK IN THE COMMENTS OF A SUBNET]
+// =====================================================================================
+
+// Replace the labels saved in the comments of an assigned subnet, the human comment and every
+// other block are kept as mira holds them. A subnet of a gated template or range is only
+// changed with a change ticket
+func (c *Client) UpdateMiraSubnetLabels(updateInput *MiraSubnetLabelsUpdateInput) error {
+
+	// refuse before anything is sent, when the provider is read only
+	if err := c.checkWritable(fmt.Sprintf("the update of the labels of subnet record %d", updateInput.RecordId)); err != nil {
+		return err
+	}
+
+	// ---------------------------------------
+	// GET THE COMMENTS OF THE ASSIGNED SUBNET
+	// ---------------------------------------
+
+	returnedSubnet, err := c.GetMiraSubnetRecordByID(&GetMiraSubnetByIDQueryInput{
+		RecordId: updateInput.RecordId,
+	})
+	if err != nil {
+		return err
+	}
+
+	// a change to a gated subnet needs a change ticket, as its assignment did
+	changeTicket, err := c.ChangeGate.Ticket(returnedSubnet.Template, returnedSubnet.Range, updateInput.ChangeTicket)
+	if err != nil {
+		return err
+	}
+
+	// keep the human comment and the other blocks, and replace the labels, and the change block
+	// with the ticket of this update when it has one
+	comment, blocks := ParseMiraComment(returnedSubnet.Comments)
+	encodeBlocks := map[string]interface{}{}
+	for name, block := range blocks {
+		encodeBlocks[name] = block
+	}
+	encodeBlocks[CommentBlockLabels] = updateInput.Labels
+	if changeTicket != "" {
+		encodeBlocks[CommentBlockChange] = changeStamp(changeTicket)
+	}
+
+	comments, err := EncodeMiraComment(comment, encodeBlocks)
+	if err != nil {
+		return err
+	}
+
+	// ------------------------------------
+	// DO PUT REQUEST TO REWRITE THE LABELS
+	// ------------------------------------
+
+	putBody, err := json.Marshal(MiraSubnetCommentsPutData{Comments: comments})
+	if err != nil {
+		return err
+	}
+
+	updateSubnetReq, err := c.newMiraRequest("PUT", fmt.Sprintf(baseURL+"subnet/%d", updateInput.RecordId), putBody)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.doRequest(updateSubnetReq)
+	return err
+}
@@ -0,0 +1,195 @@
+package miraclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// a fake mira for the unit tests, it answers a request with the body of the longest prefix of
+// its path and query the request matches, and with a 404 when it matches none
+type miraTestTransport map[string]string
+
+func (m miraTestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	matched := ""
+	for prefix := range m {
+		if strings.HasPrefix(req.URL.RequestURI(), prefix) && len(prefix) > len(matched) {
+			matched = prefix
+		}
+	}
+	response := &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}
+	if body, ok := m[matched]; ok && matched != "" {
+		response.StatusCode = http.StatusOK
+		response.Body = ioutil.NopCloser(strings.NewReader(body))
+	}
+	return response, nil
+}
+
+func TestMiraUpdateSubnetLabels(t *testing.T) {
+	gate, err := NewChangeGate([]string{"U25_PRD_GCP"}, nil, "CHG[0-9]{7}", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	client := &Client{
+		ChangeGate: gate,
+		HTTPClient: &http.Client{Transport: miraTestTransport{
+			"/subnet/4711": `{"address":"10.0.0.32","recordId":4711,"template":"U25_PRD_GCP","comments":"gke nodes #labels{\"env\":\"uat\"}"}`,
+		}},
+	}
+	update := &MiraSubnetLabelsUpdateInput{RecordId: 4711, Labels: map[string]string{"env": "prd"}}
+
+	// the labels of a gated subnet are changed like its assignment, with a change ticket
+	var gateErr *ChangeGateError
+	if err := client.UpdateMiraSubnetLabels(update); !errors.As(err, &gateErr) {
+		t.Fatalf("expected the update to be refused by the change gate, got: %v", err)
+	}
+	update.ChangeTicket = "CHG1234567"
+	if err := client.UpdateMiraSubnetLabels(update); err != nil {
+		t.Fatalf("expected the update with a ticket to pass, got: %v", err)
+	}
+
+	// a read only provider changes nothing
+	client.ReadOnly = true
+	if err := client.UpdateMiraSubnetLabels(update); !IsReadOnly(err) {
+		t.Fatalf("expected the update to be refused as read only, got: %v", err)
+	}
+}
+
+func TestMiraReadOnlyClientRefusesChanges(t *testing.T) {
+	// no url or credentials, so any request that got past the check would fail differently
+	client := &Client{ReadOnly: true}
+
+	_, err := client.CreateMiraSubnetAssignment(&MiraSubnetAssignmentPostInput{
+		RequestRange: "10.10.0.0",
+		RequestMask:  "255.255.255.224",
+	})
+	if !IsReadOnly(err) {
+		t.Fatalf("expected the assignment to be refused as read only, got: %v", err)
+	}
+
+	if err := client.DeleteMiraSubnetAssignment("10.10.0.32", ""); !IsReadOnly(err) {
+		t.Fatalf("expected the release to be refused as read only, got: %v", err)
+	}
+	if err := client.PutMiraDhcpScope(&MiraDhcpScopeInput{SubnetAddress: "10.10.0.32"}); !IsReadOnly(err) {
+		t.Fatalf("expected the dhcp scope to be refused as read only, got: %v", err)
+	}
+	if err := client.DeleteMiraHostRecord("10.10.0.33", ""); !IsReadOnly(err) {
+		t.Fatalf("expected the host release to be refused as read only, got: %v", err)
+	}
+}
+
+func TestMiraDryRunClientRefusesOtherChanges(t *testing.T) {
+	// a dry run only simulates subnet assignments, every other change is refused before a request
+	client := &Client{DryRun: true}
+	var dryRunErr *DryRunError
+
+	if err := client.DeleteMiraSubnetAssignment("10.10.0.32", ""); !errors.As(err, &dryRunErr) {
+		t.Fatalf("expected the release to be refused as a dry run, got: %v", err)
+	}
+	if err := client.PutMiraDhcpScope(&MiraDhcpScopeInput{SubnetAddress: "10.10.0.32"}); !errors.As(err, &dryRunErr) {
+		t.Fatalf("expected the dhcp scope to be refused as a dry run, got: %v", err)
+	}
+	if err := client.DeleteMiraHostRecord("10.10.0.33", ""); !errors.As(err, &dryRunErr) {
+		t.Fatalf("expected the host release to be refused as a dry run, got: %v", err)
+	}
+}
+
+func TestMiraSubnetRecordOfSubnetIgnoresRangeRecord(t *testing.T) {
+	// mira answers containsIP for an address without a record of its own with the range record
+	client := &Client{
+		HTTPClient: &http.Client{Transport: miraTestTransport{
+			"/search": `{"address":"10.0.0.0","recordId":1,"subnetName":"eu-region3-prd"}`,
+		}},
+	}
+
+	_, err := client.GetMiraSubnetRecordOfSubnet(&GetMiraSubnetFromIPAddressQueryInput{IpAddress: "10.0.0.32"})
+	if !IsNotFound(err) {
+		t.Fatalf("expected the range record to be not found, got: %v", err)
+	}
+
+	// so the range record is never released in place of the subnet
+	if err := client.DeleteMiraSubnetAssignment("10.0.0.32", ""); !IsNotFound(err) {
+		t.Fatalf("expected the release to find no record, got: %v", err)
+	}
+
+	record, err := client.GetMiraSubnetRecordOfSubnet(&GetMiraSubnetFromIPAddressQueryInput{IpAddress: "10.0.0.0"})
+	if err != nil || record.IpAddress != "10.0.0.0" {
+		t.Fatalf("expected the record of 10.0.0.0, got %v: %v", record, err)
+	}
+}
+
+func TestMiraAssignmentTimesOutWaitingForTheRangeLock(t *testing.T) {
+	client := &Client{HTTPClient: &http.Client{Transport: miraTestTransport{}}}
+
+	// another assignment from the range holds the lock for longer than the timeout
+	unlock, err := client.lockRange("10.2.0.0", time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer unlock()
+
+	_, err = client.CreateMiraSubnetAssignment(&MiraSubnetAssignmentPostInput{
+		RequestRange: "10.2.0.0",
+		RequestMask:  "255.255.255.224",
+		Timeout:      50 * time.Millisecond,
+	})
+	if err == nil || !strings.Contains(err.Error(), "waiting for range 10.2.0.0") {
+		t.Fatalf("expected a timeout waiting for the range lock, got: %v", err)
+	}
+}
+
+func TestMiraReleaseUnverifiedAssignmentOnlyReleasesOurs(t *testing.T) {
+	transport := &miraRequestLog{miraTestTransport: miraTestTransport{
+		"/search?containsIP=10.0.0.32": `{"address":"10.0.0.32","recordId":4711,"subnetName":"gke-nodes"}`,
+		"/search?containsIP=10.0.0.64": `{"address":"10.0.0.64","recordId":4712,"subnetName":"someone-else"}`,
+		"/subnet/":                     `{}`,
+	}}
+	client := &Client{HTTPClient: &http.Client{Transport: transport}}
+
+	// the post of each was never verified, the first is ours in mira and the second is not
+	for _, subnet := range []string{"10.0.0.32", "10.0.0.64", "10.0.0.96"} {
+		if err := client.ReleaseUnverifiedMiraSubnetAssignment(&MiraSubnetAssignmentResult{Subnet: subnet}, "gke-nodes", ""); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	var deleted []string
+	for _, request := range transport.requests {
+		if strings.HasPrefix(request, "DELETE ") {
+			deleted = append(deleted, request)
+		}
+	}
+	if len(deleted) != 1 || deleted[0] != "DELETE /subnet/4711" {
+		t.Fatalf("expected only the record of 10.0.0.32 to be released, got: %v", deleted)
+	}
+}
+
+func TestMiraRangeUtilizationCountsExcludedSubnets(t *testing.T) {
+	client := &Client{
+		ExcludeCIDRs: []string{"10.0.0.0/27"},
+		HTTPClient: &http.Client{Transport: miraTestTransport{
+			"/": `{"message":"OK","payload":["10.0.0.0","10.0.0.32"]}`,
+		}},
+	}
+
+	utilization, err := client.GetMiraRangeUtilization(&MiraRangeUtilizationQueryInput{
+		RequestRange:  "10.0.0.0",
+		RangeMask:     "255.255.255.0",
+		PrefixLengths: []int{27},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the excluded free subnet is neither free nor allocated
+	prefix := utilization.Prefixes[0]
+	if prefix.Total != 8 || prefix.Free != 1 || prefix.Excluded != 1 || prefix.Allocated != 6 {
+		t.Fatalf("expected 8 subnets, 1 free, 1 excluded and 6 allocated, got: %+v", prefix)
+	}
+	if utilization.LargestFreeBlock != "10.0.0.32/27" {
+		t.Fatalf("expected the largest free block to leave out the excluded subnet, got: %s", utilization.LargestFreeBlock)
+	}
+}
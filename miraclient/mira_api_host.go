@@ -0,0 +1,305 @@
+package miraclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// *************************************************************************
+// CREATE INPUT AND OUTPUT STRUCTS FOR: HOST ADDRESS RECORDS INSIDE A SUBNET
+// *************************************************************************
+
+// the record mira holds for a single host address inside a subnet
+type MiraHostRecord struct {
+	IpAddress string `json:"address"`
+	Subnet    string `json:"subnet"`
+	Name      string `json:"name"`
+	Comment   string `json:"comment"`
+	RecordId  int    `json:"recordId"`
+}
+
+// struct for mira host assignment post input variables, an empty
+// IpAddress means the next free host address in the subnet is used
+type MiraHostAssignmentPostInput struct {
+	SubnetAddress string
+	IpAddress     string
+	Name          string
+	Comment       string
+	ChangeTicket  string // required by the change gate when the subnet is gated
+}
+
+// struct for the fields of a host record that can be changed in place
+type MiraHostUpdateInput struct {
+	IpAddress    string
+	Name         string
+	Comment      string
+	ChangeTicket string // required by the change gate when the subnet is gated
+}
+
+// the post and put data sent to mira for a host record
+type MiraHostPostData struct {
+	Subnet  string `json:"subnet"`
+	Address string `json:"address"`
+	Name    string `json:"name"`
+	Comment string `json:"comment"`
+}
+
+// =================================================================================================
+// METHOD: GetAvailableHostsFromMiraSubnet [REQUEST FREE HOST ADDRESSES BY SUBNET, RETURN FREE LIST]
+// =================================================================================================
+
+// Create a http request, add authentication details and the subnet to list free host addresses from
+func (c *Client) GetAvailableHostsFromMiraSubnet(subnetAddress string) (*AvailableSubnetsResponseFromMira, error) {
+
+	// check that the subnet is in ip address format
+	if !(checkIPAddress(subnetAddress)) {
+		return nil, fmt.Errorf("Error: %s is not in IP address format, in GetAvailableHostsFromMiraSubnet", subnetAddress)
+	}
+
+	// create MIRA search free host query string, using the subnet address
+	freeHostReq, err := c.newMiraRequest("GET", fmt.Sprintf(baseURL+"searchFreeIP?subnet=%s", subnetAddress), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// do http request and return a string of the body text
+	freeHostRespBody, err := c.doRequest(freeHostReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// unmarshal the data from the response body into the same message/payload struct as free subnets
+	var unmarshaledResponseData AvailableSubnetsResponseFromMira
+	err = json.Unmarshal(freeHostRespBody, &unmarshaledResponseData)
+	if err != nil {
+		return nil, err
+	}
+
+	// check the api response was 'OK'
+	if unmarshaledResponseData.Message != "OK" {
+		return nil, fmt.Errorf("Error: mira api status was not 'OK' status: %s", unmarshaledResponseData.Message)
+	}
+
+	// check that every element is actaully an IP
+	for index, element := range unmarshaledResponseData.Payload {
+		if !(checkIPAddress(element)) {
+			return nil, fmt.Errorf("Error: %s is not a host address, at position %d mira free hosts payload %s", element, index, unmarshaledResponseData.Payload)
+		}
+	}
+
+	return &unmarshaledResponseData, nil
+}
+
+// ========================================================================================
+// METHOD: CreateMiraHostAssignment [REQUEST HOST ASSIGNMENT IN SUBNET, RETURN HOST RECORD]
+// ========================================================================================
+
+func (c *Client) CreateMiraHostAssignment(postInput *MiraHostAssignmentPostInput) (*MiraHostRecord, error) {
+
+	// refuse before anything is sent, when the provider is read only
+	if err := c.checkWritable("the host assignment"); err != nil {
+		return nil, err
+	}
+
+	// -----------
+	// CHECK INPUT
+	// -----------
+
+	// check that the subnet to be supplied to mira is in ip address format
+	if !(checkIPAddress(postInput.SubnetAddress)) {
+		return nil, fmt.Errorf("Error: %s is not a valid mira subnet", postInput.SubnetAddress)
+	}
+
+	// a host in a gated subnet needs a change ticket, which is saved after its comment
+	changeTicket, err := c.checkChangeGateForAddress(postInput.SubnetAddress, postInput.ChangeTicket)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := EncodeMiraComment(postInput.Comment, map[string]interface{}{
+		CommentBlockChange: changeStamp(changeTicket),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// -----------------------------------------------------
+	// LOCK THE SUBNET UNTIL THE HOST ADDRESS HAS BEEN POSTED
+	// -----------------------------------------------------
+
+	// hold the lock across the query, choose and post steps below, so a parallel host
+	// assignment in this provider process never chooses the same free address
+	unlockSubnet, err := c.lockHosts(postInput.SubnetAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockSubnet()
+
+	// --------------------------------------------------
+	// CHOOSE THE REQUESTED OR THE NEXT FREE HOST ADDRESS
+	// --------------------------------------------------
+
+	chosenAddress := postInput.IpAddress
+	if chosenAddress == "" {
+		// get free host addresses from the mira subnet and choose the first one
+		freeHosts, err := c.GetAvailableHostsFromMiraSubnet(postInput.SubnetAddress)
+		if err != nil {
+			return nil, err
+		}
+		if len(freeHosts.Payload) == 0 {
+			return nil, fmt.Errorf("Error: mira has no free host addresses left in subnet %s", postInput.SubnetAddress)
+		}
+		chosenAddress = freeHosts.Payload[0]
+	}
+
+	// check that the chosen address is actaully an IP... just for good measure
+	if !(checkIPAddress(chosenAddress)) {
+		return nil, fmt.Errorf("Error: %s is not a host address, but was about to be submitted to mira", chosenAddress)
+	}
+
+	// ------------------------------
+	// DO POST REQUEST TO ASSIGN HOST
+	// ------------------------------
+
+	// Encode the data for the post, from a struct to json
+	postBody, err := json.Marshal(MiraHostPostData{
+		Subnet:  postInput.SubnetAddress,
+		Address: chosenAddress,
+		Name:    postInput.Name,
+		Comment: comments,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	assignHostReq, err := c.newMiraRequest("POST", baseURL+"host", postBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = c.doRequest(assignHostReq); err != nil {
+		return nil, err
+	}
+
+	// ------------------------------------------
+	// READ BACK THE HOST RECORD MIRA HAS CREATED
+	// ------------------------------------------
+
+	// IMPORTANT if this fails the address is now assigned in mira but not in terraform
+	return c.GetMiraHostRecord(chosenAddress)
+}
+
+// lock the host addresses of a subnet for this provider process, and return the func that unlocks them
+func (c *Client) lockHosts(subnetAddress string) (func(), error) {
+	lock, _ := hostLocks.LoadOrStore(subnetAddress, make(chan struct{}, 1))
+	return acquireLock(lock.(chan struct{}), time.Time{}, "the host addresses of subnet "+subnetAddress)
+}
+
+// ==============================================================================
+// METHOD: GetMiraHostRecord [REQUEST HOST RECORD BY ADDRESS, RETURN HOST RECORD]
+// ==============================================================================
+
+func (c *Client) GetMiraHostRecord(ipAddress string) (*MiraHostRecord, error) {
+
+	// check that the host address is in ip address format
+	if !(checkIPAddress(ipAddress)) {
+		return nil, fmt.Errorf("Error: %s is not in IP address format, in GetMiraHostRecord", ipAddress)
+	}
+
+	getHostReq, err := c.newMiraRequest("GET", fmt.Sprintf(baseURL+"host/%s", ipAddress), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// do http request and return a string of the body text
+	getHostRespBody, err := c.doRequest(getHostReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// unmarshal the data from the response body json bytes into struct
+	var unmarshaledResponseData MiraHostRecord
+	err = json.Unmarshal(getHostRespBody, &unmarshaledResponseData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &unmarshaledResponseData, nil
+}
+
+// ===================================================================
+// METHOD: UpdateMiraHostRecord [CHANGE HOST NAME AND COMMENT IN MIRA]
+// ===================================================================
+
+func (c *Client) UpdateMiraHostRecord(updateInput *MiraHostUpdateInput) error {
+
+	// refuse before anything is sent, when the provider is read only
+	if err := c.checkWritable("the update of host " + updateInput.IpAddress); err != nil {
+		return err
+	}
+
+	// check that the host address is in ip address format
+	if !(checkIPAddress(updateInput.IpAddress)) {
+		return fmt.Errorf("Error: %s is not in IP address format, in UpdateMiraHostRecord", updateInput.IpAddress)
+	}
+
+	// a host in a gated subnet needs a change ticket, which is saved after its comment
+	changeTicket, err := c.checkChangeGateForAddress(updateInput.IpAddress, updateInput.ChangeTicket)
+	if err != nil {
+		return err
+	}
+	comments, err := EncodeMiraComment(updateInput.Comment, map[string]interface{}{
+		CommentBlockChange: changeStamp(changeTicket),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Encode the data for the put, from a struct to json
+	putBody, err := json.Marshal(MiraHostPostData{
+		Address: updateInput.IpAddress,
+		Name:    updateInput.Name,
+		Comment: comments,
+	})
+	if err != nil {
+		return err
+	}
+
+	updateHostReq, err := c.newMiraRequest("PUT", fmt.Sprintf(baseURL+"host/%s", updateInput.IpAddress), putBody)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.doRequest(updateHostReq)
+	return err
+}
+
+// ===========================================================
+// METHOD: DeleteMiraHostRecord [RELEASE HOST ADDRESS IN MIRA]
+// ===========================================================
+
+func (c *Client) DeleteMiraHostRecord(ipAddress string, changeTicket string) error {
+
+	// refuse before anything is sent, when the provider is read only
+	if err := c.checkWritable("the release of host " + ipAddress); err != nil {
+		return err
+	}
+
+	// check that the host address is in ip address format
+	if !(checkIPAddress(ipAddress)) {
+		return fmt.Errorf("Error: %s is not in IP address format, in DeleteMiraHostRecord", ipAddress)
+	}
+
+	// a host in a gated subnet needs a change ticket
+	if _, err := c.checkChangeGateForAddress(ipAddress, changeTicket); err != nil {
+		return err
+	}
+
+	deleteHostReq, err := c.newMiraRequest("DELETE", fmt.Sprintf(baseURL+"host/%s", ipAddress), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.doRequest(deleteHostReq)
+	return err
+}
@@ -0,0 +1,78 @@
+package miraclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a fake mira subnet that hands out its free host addresses, and only lists the ones not yet posted
+type miraHostTransport struct {
+	sync.Mutex
+	free []string
+}
+
+func (m *miraHostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	body := "{}"
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/searchFreeIP"):
+		payload, _ := json.Marshal(m.free)
+		body = fmt.Sprintf(`{"message":"OK","payload":%s}`, payload)
+
+		// a slow answer, so parallel assignments would both see the same first free address
+		m.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		m.Lock()
+	case req.Method == http.MethodPost:
+		var posted MiraHostPostData
+		if err := json.NewDecoder(req.Body).Decode(&posted); err != nil {
+			return nil, err
+		}
+		for i, address := range m.free {
+			if address == posted.Address {
+				m.free = append(m.free[:i], m.free[i+1:]...)
+			}
+		}
+	case strings.HasPrefix(req.URL.Path, "/host/"):
+		body = fmt.Sprintf(`{"address":"%s","subnet":"10.0.0.32"}`, strings.TrimPrefix(req.URL.Path, "/host/"))
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+func TestMiraParallelHostAssignmentsChooseDifferentAddresses(t *testing.T) {
+	transport := &miraHostTransport{free: []string{"10.0.0.33", "10.0.0.34", "10.0.0.35"}}
+	client := &Client{HTTPClient: &http.Client{Transport: transport}}
+
+	var wg sync.WaitGroup
+	addresses := make([]string, 2)
+	errs := make([]error, 2)
+	for i := range addresses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			record, err := client.CreateMiraHostAssignment(&MiraHostAssignmentPostInput{SubnetAddress: "10.0.0.32", Name: fmt.Sprintf("vm-%d", i)})
+			if err == nil {
+				addresses[i] = record.IpAddress
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if addresses[0] == addresses[1] {
+		t.Fatalf("expected different host addresses, both got: %s", addresses[0])
+	}
+}
@@ -0,0 +1,149 @@
+package miraclient
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// *********************************************************
+// CREATE INPUT AND OUTPUT STRUCTS FOR: QIP DHCP SCOPE CALLS
+// *********************************************************
+
+// struct for a qip dhcp scope on an existing mira subnet
+type MiraDhcpScopeInput struct {
+	SubnetAddress string
+	DhcpServer    string
+	DhcpTemplate  string
+	ChangeTicket  string // required by the change gate when the subnet is gated
+}
+
+// the post and put data sent to mira to push a subnet to qip with a dhcp scope
+type MiraDhcpScopePostData struct {
+	Subnet       string `json:"subnet"`
+	AlsoQip      bool   `json:"alsoQip"`
+	Dhcp         bool   `json:"dhcp"`
+	DhcpServer   string `json:"dhcpServer"`
+	DhcpTemplate string `json:"dhcpTemplate"`
+	Comment      string `json:"comment,omitempty"` // the change block of a gated scope
+}
+
+// the dhcp scope mira holds for a subnet, and the qip instance serving it
+type MiraDhcpScopeRecord struct {
+	Subnet       string `json:"subnet"`
+	DhcpServer   string `json:"dhcpServer"`
+	DhcpTemplate string `json:"dhcpTemplate"`
+	QipInstance  string `json:"qipInstance"`
+	Comment      string `json:"comment"` // the change block of a gated scope
+}
+
+// =================================================================================
+// METHOD: PutMiraDhcpScope [PUSH SUBNET TO QIP WITH A DHCP SCOPE, CREATE OR UPDATE]
+// =================================================================================
+
+func (c *Client) PutMiraDhcpScope(scopeInput *MiraDhcpScopeInput) error {
+
+	// refuse before anything is sent, when the provider is read only
+	if err := c.checkWritable("the dhcp scope of subnet " + scopeInput.SubnetAddress); err != nil {
+		return err
+	}
+
+	// check that the subnet is in ip address format
+	if !(checkIPAddress(scopeInput.SubnetAddress)) {
+		return fmt.Errorf("Error: %s is not in IP address format, in PutMiraDhcpScope", scopeInput.SubnetAddress)
+	}
+
+	// the scope of a gated subnet needs a change ticket, which is saved with the scope
+	changeTicket, err := c.checkChangeGateForAddress(scopeInput.SubnetAddress, scopeInput.ChangeTicket)
+	if err != nil {
+		return err
+	}
+	comments, err := EncodeMiraComment("", map[string]interface{}{
+		CommentBlockChange: changeStamp(changeTicket),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Encode the data for the put, from a struct to json
+	putBody, err := json.Marshal(MiraDhcpScopePostData{
+		Subnet:       scopeInput.SubnetAddress,
+		AlsoQip:      true, // a dhcp scope is served by qip, so the subnet is always pushed to qip
+		Dhcp:         true,
+		DhcpServer:   scopeInput.DhcpServer,
+		DhcpTemplate: scopeInput.DhcpTemplate,
+		Comment:      comments, // kept with its leading space, so the change block is found again
+	})
+	if err != nil {
+		return err
+	}
+
+	putScopeReq, err := c.newMiraRequest("PUT", fmt.Sprintf(baseURL+"qip/dhcpScope/%s", scopeInput.SubnetAddress), putBody)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.doRequest(putScopeReq)
+	return err
+}
+
+// =================================================================================
+// METHOD: GetMiraDhcpScope [REQUEST DHCP SCOPE BY SUBNET, RETURN DHCP SCOPE RECORD]
+// =================================================================================
+
+func (c *Client) GetMiraDhcpScope(subnetAddress string) (*MiraDhcpScopeRecord, error) {
+
+	// check that the subnet is in ip address format
+	if !(checkIPAddress(subnetAddress)) {
+		return nil, fmt.Errorf("Error: %s is not in IP address format, in GetMiraDhcpScope", subnetAddress)
+	}
+
+	getScopeReq, err := c.newMiraRequest("GET", fmt.Sprintf(baseURL+"qip/dhcpScope/%s", subnetAddress), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// do http request and return a string of the body text
+	getScopeRespBody, err := c.doRequest(getScopeReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// unmarshal the data from the response body json bytes into struct
+	var unmarshaledResponseData MiraDhcpScopeRecord
+	err = json.Unmarshal(getScopeRespBody, &unmarshaledResponseData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &unmarshaledResponseData, nil
+}
+
+// ===================================================================
+// METHOD: DeleteMiraDhcpScope [REMOVE THE QIP DHCP SCOPE OF A SUBNET]
+// ===================================================================
+
+func (c *Client) DeleteMiraDhcpScope(subnetAddress string, changeTicket string) error {
+
+	// refuse before anything is sent, when the provider is read only
+	if err := c.checkWritable("the removal of the dhcp scope of subnet " + subnetAddress); err != nil {
+		return err
+	}
+
+	// check that the subnet is in ip address format
+	if !(checkIPAddress(subnetAddress)) {
+		return fmt.Errorf("Error: %s is not in IP address format, in DeleteMiraDhcpScope", subnetAddress)
+	}
+
+	// the scope of a gated subnet needs a change ticket
+	if _, err := c.checkChangeGateForAddress(subnetAddress, changeTicket); err != nil {
+		return err
+	}
+
+	deleteScopeReq, err := c.newMiraRequest("DELETE", fmt.Sprintf(baseURL+"qip/dhcpScope/%s", subnetAddress), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.doRequest(deleteScopeReq)
+	return err
+}
@@ -0,0 +1,128 @@
+package miraclient
+
+import (
+	"fmt"
+)
+
+// the deepest prefix length reported when no prefix lengths are requested
+const defaultMaxUtilizationPrefixLength int = 29
+
+// ************************************************************
+// CREATE INPUT AND OUTPUT STRUCTS FOR: GetMiraRangeUtilization
+// ************************************************************
+
+// the range to report on, and the subnet prefix lengths to count
+type MiraRangeUtilizationQueryInput struct {
+	RequestRange  string
+	RangeMask     string
+	PrefixLengths []int
+}
+
+// the number of subnets of a single prefix length that fit in, are used in and are free in a range,
+// and the free ones that are never chosen as they overlap an excluded CIDR
+type MiraRangePrefixUtilization struct {
+	PrefixLength int
+	Netmask      string
+	Total        int
+	Allocated    int
+	Free         int
+	Excluded     int
+}
+
+// the usage of a range for every requested prefix length, and the largest block that is still free
+type MiraRangeUtilization struct {
+	RequestRange            string
+	RangePrefixLength       int
+	Prefixes                []MiraRangePrefixUtilization
+	LargestFreeBlock        string
+	LargestFreePrefixLength int
+}
+
+// ===================================================================================
+// METHOD: GetMiraRangeUtilization [COUNT FREE SUBNETS PER PREFIX, RETURN RANGE USAGE]
+// ===================================================================================
+
+// Query the free subnets of a range once per prefix length, and count what is used and what is free
+func (c *Client) GetMiraRangeUtilization(queryInput *MiraRangeUtilizationQueryInput) (*MiraRangeUtilization, error) {
+
+	// -----------
+	// CHECK INPUT
+	// -----------
+
+	// check that the range is in ip address format
+	if !(checkIPAddress(queryInput.RequestRange)) {
+		return nil, fmt.Errorf("Error: %s is not in IP address format, in GetMiraRangeUtilization RequestRange", queryInput.RequestRange)
+	}
+
+	// the mask of the whole range is needed to know how many subnets fit in it
+	rangePrefixLength, err := NetmaskToPrefixLength(queryInput.RangeMask)
+	if err != nil {
+		return nil, err
+	}
+
+	// default to every prefix length from the size of the range down to a /29
+	prefixLengths := queryInput.PrefixLengths
+	if len(prefixLengths) == 0 {
+		for prefixLength := rangePrefixLength; prefixLength <= defaultMaxUtilizationPrefixLength || prefixLength == rangePrefixLength; prefixLength++ {
+			prefixLengths = append(prefixLengths, prefixLength)
+		}
+	}
+
+	// ------------------------------------------------
+	// DO ONE FREE SUBNETS QUERY FOR EACH PREFIX LENGTH
+	// ------------------------------------------------
+
+	utilization := MiraRangeUtilization{
+		RequestRange:      queryInput.RequestRange,
+		RangePrefixLength: rangePrefixLength,
+	}
+
+	for _, prefixLength := range prefixLengths {
+
+		// a subnet can not be bigger than the range it is taken from
+		if prefixLength < rangePrefixLength || prefixLength > 32 {
+			return nil, fmt.Errorf("Error: a /%d subnet does not fit in the /%d range %s", prefixLength, rangePrefixLength, queryInput.RequestRange)
+		}
+
+		netmask, err := PrefixLengthToNetmask(prefixLength)
+		if err != nil {
+			return nil, err
+		}
+
+		// get free subnets of this size from the mira range
+		freeSubnets, err := c.GetAvailableSubnetsFromMiraRange(&RangeForAvailableMiraSubnetsQueryInput{
+			RequestRange: queryInput.RequestRange,
+			RequestMask:  netmask,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// the excluded subnets are free in mira but left out of the payload, so they are counted
+		// on their own, and total is always allocated, free and excluded together
+		total := 1 << uint(prefixLength-rangePrefixLength)
+		free := len(freeSubnets.Payload)
+		excluded := len(freeSubnets.Excluded)
+
+		utilization.Prefixes = append(utilization.Prefixes, MiraRangePrefixUtilization{
+			PrefixLength: prefixLength,
+			Netmask:      netmask,
+			Total:        total,
+			Allocated:    total - free - excluded,
+			Free:         free,
+			Excluded:     excluded,
+		})
+
+		// the free subnets are whole blocks, so the shortest prefix with a free subnet is the largest free block
+		if free > 0 && (utilization.LargestFreeBlock == "" || prefixLength < utilization.LargestFreePrefixLength) {
+			utilization.LargestFreeBlock = fmt.Sprintf("%s/%d", freeSubnets.Payload[0], prefixLength)
+			utilization.LargestFreePrefixLength = prefixLength
+		}
+	}
+
+	// ------------------
+	// RETURN RANGE USAGE
+	// ------------------
+
+	return &utilization, nil
+}
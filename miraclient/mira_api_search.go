@@ -0,0 +1,86 @@
+package miraclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// ******************************************
+// CREATE INPUT STRUCT FOR: SearchMiraSubnets
+// ******************************************
+
+// the filters for a search of assigned subnets, empty fields are not filtered on
+type MiraSubnetSearchQueryInput struct {
+	SubnetName string
+	AddressID  string
+	Range      string
+	Template   string
+}
+
+// =====================================================================================
+// METHOD: SearchMiraSubnets [REQUEST SUBNET RECORDS BY NAME/ADDRESS/RANGE, RETURN LIST]
+// =====================================================================================
+
+// Create a http request, add authentication details and the filters to search subnet records with
+func (c *Client) SearchMiraSubnets(queryInput *MiraSubnetSearchQueryInput) ([]MiraSubnetFoundByIPAddressResponseData, error) {
+
+	// -----------
+	// CHECK INPUT
+	// -----------
+
+	// a range filter must be an ip, like every other range given to mira
+	if queryInput.Range != "" && !(checkIPAddress(queryInput.Range)) {
+		return nil, fmt.Errorf("Error: %s is not in IP address format, in SearchMiraSubnets Range", queryInput.Range)
+	}
+
+	// only add the filters that were provided to the query string
+	query := url.Values{}
+	if queryInput.SubnetName != "" {
+		query.Set("subnetName", queryInput.SubnetName)
+	}
+	if queryInput.AddressID != "" {
+		query.Set("addressID", queryInput.AddressID)
+	}
+	if queryInput.Range != "" {
+		query.Set("range", queryInput.Range)
+	}
+	if queryInput.Template != "" {
+		query.Set("template", queryInput.Template)
+	}
+
+	// an unfiltered search would return every subnet in mira
+	if len(query) == 0 {
+		return nil, fmt.Errorf("Error: at least one filter is required, in SearchMiraSubnets")
+	}
+
+	// -------------------
+	// DO MIRA API REQUEST
+	// -------------------
+
+	searchSubnetsReq, err := c.newMiraRequest("GET", baseURL+"search?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// do http request and return a string of the body text
+	searchSubnetsRespBody, err := c.doRequest(searchSubnetsReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// --------------------
+	// UNMARSHAL JSON BYTES
+	// --------------------
+
+	// create a slice for the api response body bytes
+	var unmarshaledResponseData []MiraSubnetFoundByIPAddressResponseData
+
+	// unmarshal the data from the response body json bytes into the slice
+	err = json.Unmarshal(searchSubnetsRespBody, &unmarshaledResponseData)
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshaledResponseData, nil
+}
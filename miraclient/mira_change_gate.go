@@ -0,0 +1,141 @@
+package miraclient
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// the name of the block holding the change ticket an assignment was approved with
+const CommentBlockChange string = "change"
+
+// ************************************************
+// CREATE CHANGE GATE STRUCTS FOR: APPROVED CHANGES
+// ************************************************
+
+// the templates and ranges that can only be changed with an approved change ticket, eg: the
+// production templates. Ranges are matched as in the policy, by address or by a CIDR holding them
+type ChangeGate struct {
+	Templates     []string
+	Ranges        []string
+	TicketPattern *regexp.Regexp // every change ticket must match this
+	TicketEnvVar  string         // the environment variable read when a resource gives no ticket
+}
+
+// the change block saved with a subnet, the ticket its assignment was approved with
+type ChangeStamp struct {
+	Ticket string `json:"ticket"`
+}
+
+// the error returned for a gated change without a valid change ticket
+type ChangeGateError struct {
+	Reason string
+}
+
+func (e *ChangeGateError) Error() string {
+	return fmt.Sprintf("Error: the provider change gate refused the change: %s", e.Reason)
+}
+
+// =================================================================
+// FUNC: NewChangeGate [PARSE THE TICKET PATTERN, RETURN ANY ERRORS]
+// =================================================================
+
+func NewChangeGate(templates []string, ranges []string, ticketPattern string, ticketEnvVar string) (*ChangeGate, error) {
+
+	// the whole ticket must match, not just a part of it
+	pattern, err := regexp.Compile("^(?:" + ticketPattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("Error: could not parse the change_gate ticket_pattern: %s", err)
+	}
+
+	if len(templates) == 0 && len(ranges) == 0 {
+		return nil, fmt.Errorf("Error: the change_gate needs the templates or ranges it gates")
+	}
+
+	return &ChangeGate{
+		Templates:     templates,
+		Ranges:        ranges,
+		TicketPattern: pattern,
+		TicketEnvVar:  ticketEnvVar,
+	}, nil
+}
+
+// ============================================================================
+// METHOD: Gates [RETURN THE REASON A TEMPLATE OR RANGE IS GATED, EMPTY IF NOT]
+// ============================================================================
+
+// a nil change gate gates nothing
+func (g *ChangeGate) Gates(template string, mirarange string) string {
+	if g == nil {
+		return ""
+	}
+	if containsString(g.Templates, template) {
+		return fmt.Sprintf("the template %q is gated", template)
+	}
+	if gated := matchPolicyRange(g.Ranges, mirarange); gated != "" {
+		return fmt.Sprintf("the range %s is gated (%s)", mirarange, gated)
+	}
+	return ""
+}
+
+// ====================================================================================
+// METHOD: Ticket [RETURN THE CHANGE TICKET FOR A CHANGE, OR AN ERROR IF IT IS MISSING]
+// ====================================================================================
+
+// Return the ticket of a change to a template and range, the ticket given by the resource
+// or else the one in the environment variable. A change that is not gated needs no ticket,
+// but a ticket given anyway is still returned, so it is saved with the subnet
+func (g *ChangeGate) Ticket(template string, mirarange string, ticket string) (string, error) {
+	if ticket == "" && g != nil && g.TicketEnvVar != "" {
+		ticket = strings.TrimSpace(os.Getenv(g.TicketEnvVar))
+	}
+
+	reason := g.Gates(template, mirarange)
+	if reason == "" {
+		return ticket, nil
+	}
+
+	if ticket == "" {
+		missing := "set change_ticket on the resource"
+		if g.TicketEnvVar != "" {
+			missing += fmt.Sprintf(", or the %s environment variable", g.TicketEnvVar)
+		}
+		return "", &ChangeGateError{Reason: fmt.Sprintf("%s, so a change ticket is required: %s", reason, missing)}
+	}
+	if !g.TicketPattern.MatchString(ticket) {
+		return "", &ChangeGateError{Reason: fmt.Sprintf("%s, and the change ticket %q does not match %s", reason, ticket, g.TicketPattern)}
+	}
+	return ticket, nil
+}
+
+// ===========================================================================================
+// METHOD: checkChangeGateForAddress [LOOK UP THE SUBNET HOLDING AN ADDRESS, CHECK ITS TICKET]
+// ===========================================================================================
+
+// Check a change to an address, or to the subnet at it, against the change gate, and return the
+// ticket to save with it. The template and range are those of the mira record holding the
+// address, so the host addresses and dhcp scopes of a gated subnet are gated too. Without a gate
+// nothing is looked up, and the ticket given is returned as it is
+func (c *Client) checkChangeGateForAddress(ipAddress string, ticket string) (string, error) {
+	if c.ChangeGate == nil {
+		return ticket, nil
+	}
+
+	record, err := c.GetMiraSubnetRecordFromIPAddress(&GetMiraSubnetFromIPAddressQueryInput{
+		IpAddress: ipAddress,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error: could not look up the subnet of %s for the change gate: %s", ipAddress, err)
+	}
+
+	return c.ChangeGate.Ticket(record.Template, record.Range, ticket)
+}
+
+// func to stamp a subnet with its change ticket, nil when there is none
+func changeStamp(ticket string) *ChangeStamp {
+	if ticket == "" {
+		return nil
+	}
+	return &ChangeStamp{Ticket: ticket}
+}
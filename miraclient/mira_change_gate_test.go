@@ -0,0 +1,145 @@
+package miraclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMiraChangeGate(t *testing.T) {
+	gate, err := NewChangeGate([]string{"U25_PRD_GCP"}, []string{"10.20.0.0/14"}, "CHG[0-9]{7}", "MIRA_TEST_CHANGE_TICKET")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	os.Unsetenv("MIRA_TEST_CHANGE_TICKET")
+
+	// a change outside the gate needs no ticket
+	if ticket, err := gate.Ticket("U25_DEV_GCP", "10.10.0.0", ""); err != nil || ticket != "" {
+		t.Fatalf("expected no ticket and no error, got %q: %v", ticket, err)
+	}
+
+	// a gated template or range needs a ticket matching the whole pattern
+	for _, change := range [][2]string{{"U25_PRD_GCP", "10.10.0.0"}, {"U25_DEV_GCP", "10.21.0.0"}} {
+		if _, err := gate.Ticket(change[0], change[1], ""); err == nil {
+			t.Fatalf("expected %v to need a ticket", change)
+		}
+		if _, err := gate.Ticket(change[0], change[1], "see CHG1234567"); err == nil {
+			t.Fatalf("expected %v to refuse a ticket not matching the pattern", change)
+		}
+		if ticket, err := gate.Ticket(change[0], change[1], "CHG1234567"); err != nil || ticket != "CHG1234567" {
+			t.Fatalf("expected %v to take the ticket, got %q: %v", change, ticket, err)
+		}
+	}
+
+	// the environment variable is used when the resource gives no ticket
+	os.Setenv("MIRA_TEST_CHANGE_TICKET", "CHG7654321")
+	defer os.Unsetenv("MIRA_TEST_CHANGE_TICKET")
+	if ticket, err := gate.Ticket("U25_PRD_GCP", "10.10.0.0", ""); err != nil || ticket != "CHG7654321" {
+		t.Fatalf("expected the ticket of the environment, got %q: %v", ticket, err)
+	}
+
+	// the ticket is saved after the comment, and left out when there is none
+	comments, err := EncodeMiraComment("gke nodes", map[string]interface{}{
+		CommentBlockChange: &ChangeStamp{Ticket: "CHG7654321"},
+	})
+	if err != nil || comments != `gke nodes #change{"ticket":"CHG7654321"}` {
+		t.Fatalf("unexpected comments %q: %v", comments, err)
+	}
+}
+
+func TestMiraChangeGateRefusesReleases(t *testing.T) {
+	gate, err := NewChangeGate([]string{"U25_PRD_GCP"}, nil, "CHG[0-9]{7}", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	client := &Client{
+		ChangeGate: gate,
+		HTTPClient: &http.Client{Transport: miraTestTransport{
+			"/search":  `{"address":"10.0.0.32","recordId":7,"template":"U25_PRD_GCP","range":"10.0.0.0"}`,
+			"/subnet/": `{}`,
+		}},
+	}
+	var gateErr *ChangeGateError
+
+	// the subnet, its host addresses and its dhcp scope are all gated by the template of the subnet
+	if err := client.DeleteMiraSubnetAssignment("10.0.0.32", ""); !errors.As(err, &gateErr) {
+		t.Fatalf("expected the release to be refused by the change gate, got: %v", err)
+	}
+	if err := client.DeleteMiraHostRecord("10.0.0.33", ""); !errors.As(err, &gateErr) {
+		t.Fatalf("expected the host release to be refused by the change gate, got: %v", err)
+	}
+	if err := client.DeleteMiraDhcpScope("10.0.0.32", ""); !errors.As(err, &gateErr) {
+		t.Fatalf("expected the dhcp scope removal to be refused by the change gate, got: %v", err)
+	}
+
+	if err := client.DeleteMiraSubnetAssignment("10.0.0.32", "CHG1234567"); err != nil {
+		t.Fatalf("expected the release with a ticket to pass, got: %v", err)
+	}
+}
+
+// a fake mira that also keeps every request body it was sent, by method and path
+type miraRecordingTransport struct {
+	miraTestTransport
+	sent map[string]string
+}
+
+func (m *miraRecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		m.sent[req.Method+" "+req.URL.Path] = string(body)
+	}
+	return m.miraTestTransport.RoundTrip(req)
+}
+
+func TestMiraChangeTicketIsSaved(t *testing.T) {
+	gate, err := NewChangeGate([]string{"U25_PRD_GCP"}, nil, "CHG[0-9]{7}", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	transport := &miraRecordingTransport{
+		miraTestTransport: miraTestTransport{
+			"/search":         `{"address":"10.0.0.32","recordId":4711,"template":"U25_PRD_GCP","range":"10.0.0.0"}`,
+			"/host":           `{"address":"10.0.0.33","subnet":"10.0.0.32","recordId":4712}`,
+			"/qip/dhcpScope/": `{}`,
+			"/subnet/4711":    `{"address":"10.0.0.32","recordId":4711,"template":"U25_PRD_GCP","comments":"gke nodes #change{\"ticket\":\"CHG1111111\"}"}`,
+		},
+		sent: map[string]string{},
+	}
+	client := &Client{ChangeGate: gate, HTTPClient: &http.Client{Transport: transport}}
+
+	// every change to a gated subnet saves the ticket that approved it
+	_, err = client.CreateMiraHostAssignment(&MiraHostAssignmentPostInput{SubnetAddress: "10.0.0.32", IpAddress: "10.0.0.33", Name: "vip", Comment: "web vip", ChangeTicket: "CHG1234567"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = client.UpdateMiraHostRecord(&MiraHostUpdateInput{IpAddress: "10.0.0.33", Name: "vip", Comment: "web vip", ChangeTicket: "CHG2345678"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = client.PutMiraDhcpScope(&MiraDhcpScopeInput{SubnetAddress: "10.0.0.32", DhcpServer: "dhcp01", ChangeTicket: "CHG3456789"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = client.UpdateMiraSubnetLabels(&MiraSubnetLabelsUpdateInput{RecordId: 4711, Labels: map[string]string{"env": "prd"}, ChangeTicket: "CHG4567890"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]string{
+		"POST /host":                   `web vip #change{\"ticket\":\"CHG1234567\"}`,
+		"PUT /host/10.0.0.33":          `web vip #change{\"ticket\":\"CHG2345678\"}`,
+		"PUT /qip/dhcpScope/10.0.0.32": `"comment":" #change{\"ticket\":\"CHG3456789\"}`,
+		"PUT /subnet/4711":             `gke nodes #change{\"ticket\":\"CHG4567890\"} #labels{\"env\":\"prd\"}`,
+	}
+	for request, comment := range expected {
+		if !strings.Contains(transport.sent[request], comment) {
+			t.Fatalf("expected %s to save %s, sent: %s", request, comment, transport.sent[request])
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package miraclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// mira has no labels or other metadata on a subnet, so the provider keeps it in the comments
+// field after the human comment, as blocks of a name and a json object, eg:
+//
+//	gke nodes for team a #labels{"env":"prd","owner":"team-a"}
+//
+// a block starts with " #" and its name, and the blocks run to the end of the comments
+var commentBlockStart = regexp.MustCompile(` #([a-z_]+)\{`)
+var commentBlockName = regexp.MustCompile(`^[a-z_]+$`)
+
+// the names of the blocks holding the labels of a subnet, and who owns it
+const CommentBlockLabels string = "labels"
+const CommentBlockOwner string = "owner"
+
+// *****************************************************
+// CREATE OWNERSHIP STRUCTS FOR: THE OWNER COMMENT BLOCK
+// *****************************************************
+
+// where the subnets assigned by this provider are managed, set once by the provider configure
+type Ownership struct {
+	Workspace       string
+	Repository      string
+	ProviderVersion string
+}
+
+// the owner block saved with a subnet, when and by which type of resource it was assigned
+type OwnershipStamp struct {
+	Workspace       string `json:"workspace"`
+	Repository      string `json:"repository,omitempty"`
+	Resource        string `json:"resource,omitempty"` // the resource type, providers are never told the resource address
+	ProviderVersion string `json:"provider_version"`
+	Created         string `json:"created"`
+}
+
+// func to stamp a subnet assigned now by a resource, nil when stamping is turned off
+func (o *Ownership) stamp(resourceType string) *OwnershipStamp {
+	if o == nil {
+		return nil
+	}
+	return &OwnershipStamp{
+		Workspace:       o.Workspace,
+		Repository:      o.Repository,
+		Resource:        resourceType,
+		ProviderVersion: o.ProviderVersion,
+		Created:         time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// =====================================================================
+// FUNC: EncodeMiraComment [RETURN THE HUMAN COMMENT FOLLOWED BY BLOCKS]
+// =====================================================================
+
+// Encode a human comment and its blocks into a mira comments field. Blocks are written in
+// name order and empty ones are left out, so the same input always gives the same comments
+func EncodeMiraComment(comment string, blocks map[string]interface{}) (string, error) {
+
+	names := make([]string, 0, len(blocks))
+	for name := range blocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var encoded strings.Builder
+	encoded.WriteString(comment)
+	for _, name := range names {
+		if !commentBlockName.MatchString(name) {
+			return "", fmt.Errorf("Error: %q is not a valid comment block name, use lower case letters and underscores", name)
+		}
+
+		// a block must be a json object, so it can be found again by its opening brace
+		value, err := json.Marshal(blocks[name])
+		if err != nil {
+			return "", err
+		}
+		if string(value) == "{}" || string(value) == "null" {
+			continue
+		}
+		if !bytes.HasPrefix(value, []byte("{")) {
+			return "", fmt.Errorf("Error: the comment block %q is not a json object", name)
+		}
+
+		encoded.WriteString(" #" + name)
+		encoded.Write(value)
+	}
+
+	return encoded.String(), nil
+}
+
+// ==================================================================================
+// FUNC: ParseMiraComment [SPLIT MIRA COMMENTS INTO THE HUMAN COMMENT AND ITS BLOCKS]
+// ==================================================================================
+
+// Parse a mira comments field written by EncodeMiraComment. Comments without blocks, or with
+// text that only looks like a block, are returned whole as the human comment
+func ParseMiraComment(comments string) (string, map[string]json.RawMessage) {
+
+	// the blocks start at the first block opening that is followed by nothing but blocks
+	for _, start := range commentBlockStart.FindAllStringIndex(comments, -1) {
+		if blocks, ok := parseMiraCommentBlocks(comments[start[0]:]); ok {
+			return comments[:start[0]], blocks
+		}
+	}
+
+	return comments, map[string]json.RawMessage{}
+}
+
+// parse a run of blocks, ok is false when anything other than blocks is found
+func parseMiraCommentBlocks(tail string) (map[string]json.RawMessage, bool) {
+	blocks := map[string]json.RawMessage{}
+
+	for tail != "" {
+		opening := commentBlockStart.FindStringSubmatchIndex(tail)
+		if opening == nil || opening[0] != 0 {
+			return nil, false
+		}
+		name := tail[opening[2]:opening[3]]
+
+		// decode one json object from the opening brace, and carry on after it
+		decoder := json.NewDecoder(strings.NewReader(tail[opening[1]-1:]))
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, false
+		}
+		blocks[name] = value
+		tail = tail[opening[1]-1+int(decoder.InputOffset()):]
+	}
+
+	return blocks, true
+}
+
+// ===========================================================================
+// FUNC: MiraCommentLabels [RETURN THE LABELS IN MIRA COMMENTS, EMPTY IF NONE]
+// ===========================================================================
+
+func MiraCommentLabels(comments string) (map[string]string, error) {
+	labels := map[string]string{}
+
+	_, blocks := ParseMiraComment(comments)
+	if block, ok := blocks[CommentBlockLabels]; ok {
+		if err := json.Unmarshal(block, &labels); err != nil {
+			return nil, fmt.Errorf("Error: could not parse the labels in the mira comments %q: %s", comments, err)
+		}
+	}
+
+	return labels, nil
+}
+
+// ==========================================================================
+// FUNC: MiraCommentOwner [RETURN THE OWNER STAMPED IN MIRA COMMENTS, OR NIL]
+// ==========================================================================
+
+func MiraCommentOwner(comments string) (*OwnershipStamp, error) {
+	_, blocks := ParseMiraComment(comments)
+	block, ok := blocks[CommentBlockOwner]
+	if !ok {
+		return nil, nil
+	}
+
+	var owner OwnershipStamp
+	if err := json.Unmarshal(block, &owner); err != nil {
+		return nil, fmt.Errorf("Error: could not parse the owner in the mira comments %q: %s", comments, err)
+	}
+	return &owner, nil
+}
+
+// ===================================================================================
+// FUNC: MiraCommentChange [RETURN THE CHANGE TICKET SAVED IN MIRA COMMENTS, OR EMPTY]
+// ===================================================================================
+
+func MiraCommentChange(comments string) (string, error) {
+	_, blocks := ParseMiraComment(comments)
+	block, ok := blocks[CommentBlockChange]
+	if !ok {
+		return "", nil
+	}
+
+	var change ChangeStamp
+	if err := json.Unmarshal(block, &change); err != nil {
+		return "", fmt.Errorf("Error: could not parse the change in the mira comments %q: %s", comments, err)
+	}
+	return change.Ticket, nil
+}
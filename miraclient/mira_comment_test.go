@@ -0,0 +1,61 @@
+package miraclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMiraCommentLabelsRoundTrip(t *testing.T) {
+	labels := map[string]string{"env": "prd", "owner": "team-a", "repo": "github.com/org/net #1"}
+
+	comments, err := EncodeMiraComment("gke nodes #2 for team a", map[string]interface{}{
+		CommentBlockLabels: labels,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the human comment comes back whole, even when it holds a "#"
+	comment, _ := ParseMiraComment(comments)
+	if comment != "gke nodes #2 for team a" {
+		t.Fatalf("expected the human comment back, got %q from %q", comment, comments)
+	}
+
+	parsed, err := MiraCommentLabels(comments)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(parsed, labels) {
+		t.Fatalf("expected %v, got %v from %q", labels, parsed, comments)
+	}
+
+	// comments written outside terraform have no labels
+	parsed, err = MiraCommentLabels("assigned by hand #labels are not used here")
+	if err != nil || len(parsed) != 0 {
+		t.Fatalf("expected no labels, got %v (%v)", parsed, err)
+	}
+}
+
+func TestMiraCommentOwnerIgnoredByLabels(t *testing.T) {
+	stamp := &OwnershipStamp{Workspace: "prd", Resource: "mira_allocated_subnet_resource", ProviderVersion: "1.2.0", Created: "2026-10-18T09:00:00Z"}
+
+	comments, err := EncodeMiraComment("gke nodes", map[string]interface{}{
+		CommentBlockLabels: map[string]string{"env": "prd"},
+		CommentBlockOwner:  stamp,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the owner block does not change the comment or the labels read back
+	comment, _ := ParseMiraComment(comments)
+	labels, err := MiraCommentLabels(comments)
+	if err != nil || comment != "gke nodes" || len(labels) != 1 || labels["env"] != "prd" {
+		t.Fatalf("expected the comment and labels back, got %q and %v (%v) from %q", comment, labels, err, comments)
+	}
+
+	owner, err := MiraCommentOwner(comments)
+	if err != nil || owner == nil || *owner != *stamp {
+		t.Fatalf("expected the owner back, got %v (%v) from %q", owner, err, comments)
+	}
+}
@@ -0,0 +1,23 @@
+package miraclient
+
+// ***************************************************
+// CREATE DEFAULTS STRUCT FOR: PROVIDER LEVEL DEFAULTS
+// ***************************************************
+
+// the values a subnet resource is given when it leaves them out, empty fields have no default
+type SubnetDefaults struct {
+	AddressID     string
+	Template      string
+	RequestRange  string
+	RequestMask   string
+	CommentPrefix string // put in front of every comment sent to mira, eg: "[platform] "
+}
+
+// a named mira range of the provider range catalog, so resources can give the name instead
+type CatalogRange struct {
+	Name         string
+	RequestRange string
+	RequestMask  string
+	Template     string
+	AddressID    string
+}
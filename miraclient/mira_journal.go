@@ -0,0 +1,311 @@
+package miraclient
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// the phases of a journal entry, an intent is written before the post and
+// stays pending until a commit, abort or release entry with the same id follows.
+// A posted entry only adds the record id mira gave the subnet to its intent
+const (
+	JournalPhaseIntent   string = "intent"
+	JournalPhasePosted   string = "posted"
+	JournalPhaseCommit   string = "commit"
+	JournalPhaseAbort    string = "abort"
+	JournalPhaseReleased string = "released"
+)
+
+// pending entries younger than this may belong to an apply that is still running, so they are never aborted or released
+const journalReconcileMinAge time.Duration = time.Hour
+
+// ***********************************************
+// CREATE JOURNAL STRUCTS FOR: WRITE AHEAD JOURNAL
+// ***********************************************
+
+// one line of the journal file
+type JournalEntry struct {
+	ID         string    `json:"id"`
+	Phase      string    `json:"phase"`
+	Subnet     string    `json:"subnet"`
+	Mask       string    `json:"mask"`
+	Range      string    `json:"range"`
+	SubnetName string    `json:"subnetName"`
+	RecordId   int       `json:"recordId,omitempty"` // zero until the assignment was read back
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// a local append only file of assignment intents and commits, so a subnet posted to
+// mira by an apply that was killed before it saved state can be found again
+type Journal struct {
+	Path string
+
+	// serialise writes from parallel creates in this provider process
+	mu sync.Mutex
+}
+
+// the outcome of checking the pending journal entries against mira
+type JournalReconciliation struct {
+	Orphans  []JournalEntry // assigned in mira but never saved to state
+	Released []JournalEntry // orphans that were released in mira
+}
+
+// ==============================================================
+// FUNC: OpenJournal [RETURN A JOURNAL WRITING TO THE GIVEN FILE]
+// ==============================================================
+
+func OpenJournal(path string) *Journal {
+	return &Journal{Path: path}
+}
+
+// ==============================================================
+// METHOD: append [WRITE ONE ENTRY AS A LINE OF JSON TO THE FILE]
+// ==============================================================
+
+func (j *Journal) append(entry JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// open for append on every write, so each line is written whole and nothing is held open
+	journalFile, err := os.OpenFile(j.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("Error: could not open mira journal %s: %s", j.Path, err)
+	}
+	defer journalFile.Close()
+
+	if _, err := journalFile.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("Error: could not write to mira journal %s: %s", j.Path, err)
+	}
+
+	// the entry must be on disk before the post it guards is sent
+	return journalFile.Sync()
+}
+
+// ========================================================================
+// METHOD: Intent [RECORD A SUBNET ABOUT TO BE POSTED, RETURN THE ENTRY ID]
+// ========================================================================
+
+// a nil journal is turned off, so every method below does nothing
+func (j *Journal) Intent(subnet string, mask string, mirarange string, subnetName string) (string, error) {
+	if j == nil {
+		return "", nil
+	}
+
+	now := time.Now().UTC()
+	entry := JournalEntry{
+		ID:         fmt.Sprintf("%d-%s", now.UnixNano(), subnet),
+		Phase:      JournalPhaseIntent,
+		Subnet:     subnet,
+		Mask:       mask,
+		Range:      mirarange,
+		SubnetName: subnetName,
+		Timestamp:  now,
+	}
+
+	return entry.ID, j.append(entry)
+}
+
+// ============================================================================
+// METHOD: Posted [RECORD THE RECORD ID OF A VERIFIED ASSIGNMENT ON ITS INTENT]
+// ============================================================================
+
+// the subnet of the intent was read back from mira as ours, with this record id
+func (j *Journal) Posted(id string, recordId int) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(JournalEntry{ID: id, Phase: JournalPhasePosted, RecordId: recordId, Timestamp: time.Now().UTC()})
+}
+
+// ====================================================================
+// METHOD: Commit / Abort [CLOSE AN INTENT AS SAVED OR AS NEVER POSTED]
+// ====================================================================
+
+// the subnet of the intent has been saved to terraform state
+func (j *Journal) Commit(id string) error {
+	return j.close(id, JournalPhaseCommit, "")
+}
+
+// the subnet of the intent was not assigned to us, eg: it was taken by someone else
+func (j *Journal) Abort(id string) error {
+	return j.close(id, JournalPhaseAbort, "")
+}
+
+// every pending intent for the subnet has been saved to terraform state, eg: by adopt_existing
+func (j *Journal) CommitSubnet(subnet string) error {
+	return j.close("", JournalPhaseCommit, subnet)
+}
+
+// every pending intent for the record is in terraform state, eg: read by a refresh after a
+// commit was lost, so it is never released as an orphan
+func (j *Journal) CommitRecord(recordId int) error {
+	if j == nil || recordId == 0 {
+		return nil
+	}
+
+	pending, err := j.Pending()
+	if err != nil {
+		return err
+	}
+	for _, entry := range pending {
+		if entry.RecordId == recordId {
+			if err := j.append(JournalEntry{ID: entry.ID, Phase: JournalPhaseCommit, Subnet: entry.Subnet, RecordId: recordId, Timestamp: time.Now().UTC()}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (j *Journal) close(id string, phase string, subnet string) error {
+	if j == nil {
+		return nil
+	}
+
+	// close a single intent by its id
+	if id != "" {
+		return j.append(JournalEntry{ID: id, Phase: phase, Timestamp: time.Now().UTC()})
+	}
+
+	// or close every pending intent for a subnet
+	pending, err := j.Pending()
+	if err != nil {
+		return err
+	}
+	for _, entry := range pending {
+		if entry.Subnet == subnet {
+			if err := j.append(JournalEntry{ID: entry.ID, Phase: phase, Subnet: subnet, Timestamp: time.Now().UTC()}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ======================================================================
+// METHOD: Pending [READ THE FILE, RETURN INTENTS THAT WERE NEVER CLOSED]
+// ======================================================================
+
+func (j *Journal) Pending() ([]JournalEntry, error) {
+	if j == nil {
+		return nil, nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// no journal file yet means nothing was ever posted
+	journalFile, err := os.Open(j.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error: could not open mira journal %s: %s", j.Path, err)
+	}
+	defer journalFile.Close()
+
+	// keep the intents in file order, and drop each one once a closing entry is seen
+	var order []string
+	intents := map[string]JournalEntry{}
+
+	scanner := bufio.NewScanner(journalFile)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// a line cut short by a killed process is skipped, not fatal
+			continue
+		}
+		if entry.Phase == JournalPhaseIntent {
+			order = append(order, entry.ID)
+			intents[entry.ID] = entry
+		} else if entry.Phase == JournalPhasePosted {
+			if intent, ok := intents[entry.ID]; ok {
+				intent.RecordId = entry.RecordId
+				intents[entry.ID] = intent
+			}
+		} else {
+			delete(intents, entry.ID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error: could not read mira journal %s: %s", j.Path, err)
+	}
+
+	var pending []JournalEntry
+	for _, id := range order {
+		if entry, ok := intents[id]; ok {
+			pending = append(pending, entry)
+		}
+	}
+	return pending, nil
+}
+
+// =================================================================================
+// METHOD: ReconcileJournal [CHECK PENDING INTENTS IN MIRA, RETURN ORPHANED SUBNETS]
+// =================================================================================
+
+// Look every pending intent older than an hour up in mira, younger ones may belong to a running
+// apply and are not looked up, so a provider start costs nothing after a clean apply. One that mira
+// has no record of was never assigned and is aborted, one that mira has assigned is an orphan,
+// which is released when release is true, with the change ticket the change gate asks for. Only
+// an orphan whose record id and subnet match what the journal recorded is released, so a subnet
+// saved to state under that record, or assigned again since, is never released
+func (c *Client) ReconcileJournal(release bool, changeTicket string) (*JournalReconciliation, error) {
+	var reconciliation JournalReconciliation
+
+	pending, err := c.Journal.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range pending {
+
+		// an entry this young can belong to an apply that is still running
+		if time.Since(entry.Timestamp) < journalReconcileMinAge {
+			continue
+		}
+
+		// look up the subnet of the intent in mira
+		returnedSubnet, err := c.GetMiraSubnetRecordOfSubnet(&GetMiraSubnetFromIPAddressQueryInput{
+			IpAddress: entry.Subnet,
+		})
+		if err != nil && !IsNotFound(err) {
+			return nil, err
+		}
+
+		// the post never made it, or the subnet went to someone else, so there is nothing to recover
+		if err != nil || (returnedSubnet.SubnetName != "" && returnedSubnet.SubnetName != entry.SubnetName) ||
+			(entry.RecordId != 0 && returnedSubnet.RecordId != entry.RecordId) {
+			if err := c.Journal.Abort(entry.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// the subnet is ours in mira but was never saved to terraform state. Without a record id
+		// the apply stopped before reading it back, so it is only reported, never released
+		if release && entry.RecordId != 0 && returnedSubnet.IpAddress == entry.Subnet && returnedSubnet.IpMask == entry.Mask {
+			if err := c.DeleteMiraSubnetAssignment(entry.Subnet, changeTicket); err != nil {
+				return nil, err
+			}
+			if err := c.Journal.close(entry.ID, JournalPhaseReleased, ""); err != nil {
+				return nil, err
+			}
+			reconciliation.Released = append(reconciliation.Released, entry)
+			continue
+		}
+
+		reconciliation.Orphans = append(reconciliation.Orphans, entry)
+	}
+
+	return &reconciliation, nil
+}
@@ -0,0 +1,82 @@
+package miraclient
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// a fake mira that also keeps every method and path it was sent, in order
+type miraRequestLog struct {
+	miraTestTransport
+	requests []string
+}
+
+func (m *miraRequestLog) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.requests = append(m.requests, req.Method+" "+req.URL.RequestURI())
+	return m.miraTestTransport.RoundTrip(req)
+}
+
+func TestMiraReconcileJournalReleasesMatchingRecordsOnly(t *testing.T) {
+	journal := OpenJournal(filepath.Join(t.TempDir(), "mira.journal"))
+	settled := time.Now().UTC().Add(-2 * journalReconcileMinAge)
+
+	// write the intents as an apply would, settled unless said otherwise
+	intent := func(subnet string, recordId int, timestamp time.Time) {
+		id := "id-" + subnet
+		if err := journal.append(JournalEntry{ID: id, Phase: JournalPhaseIntent, Subnet: subnet, Mask: "255.255.255.224", Range: "10.0.0.0", SubnetName: "gke-nodes", Timestamp: timestamp}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if recordId != 0 {
+			if err := journal.Posted(id, recordId); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+	}
+	intent("10.0.0.32", 4711, settled)           // still ours, so released
+	intent("10.0.0.64", 4712, settled)           // released and assigned again, so aborted
+	intent("10.0.0.96", 0, settled)              // never read back, so only reported
+	intent("10.0.0.128", 4714, settled)          // in state, so committed by its refresh
+	intent("10.0.0.160", 4715, time.Now().UTC()) // may belong to a running apply
+
+	if err := journal.CommitRecord(4714); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	transport := &miraRequestLog{miraTestTransport: miraTestTransport{
+		"/search?containsIP=10.0.0.32":  `{"address":"10.0.0.32","mask":"255.255.255.224","recordId":4711,"subnetName":"gke-nodes"}`,
+		"/search?containsIP=10.0.0.64":  `{"address":"10.0.0.64","mask":"255.255.255.224","recordId":4800,"subnetName":"gke-nodes"}`,
+		"/search?containsIP=10.0.0.96":  `{"address":"10.0.0.96","mask":"255.255.255.224","recordId":4713,"subnetName":"gke-nodes"}`,
+		"/search?containsIP=10.0.0.128": `{"address":"10.0.0.128","mask":"255.255.255.224","recordId":4714,"subnetName":"gke-nodes"}`,
+		"/search?containsIP=10.0.0.160": `{"address":"10.0.0.160","mask":"255.255.255.224","recordId":4715,"subnetName":"gke-nodes"}`,
+		"/subnet/":                      `{}`,
+	}}
+	client := &Client{Journal: journal, HTTPClient: &http.Client{Transport: transport}}
+
+	reconciliation, err := client.ReconcileJournal(true, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(reconciliation.Released) != 1 || reconciliation.Released[0].Subnet != "10.0.0.32" {
+		t.Fatalf("expected only 10.0.0.32 to be released, got: %v", reconciliation.Released)
+	}
+	if len(reconciliation.Orphans) != 1 || reconciliation.Orphans[0].Subnet != "10.0.0.96" {
+		t.Fatalf("expected only 10.0.0.96 to be reported, got: %v", reconciliation.Orphans)
+	}
+	for _, request := range transport.requests {
+		if request == "DELETE /subnet/4712" || request == "DELETE /subnet/4800" || request == "DELETE /subnet/4714" || request == "GET /search?containsIP=10.0.0.160" {
+			t.Fatalf("unexpected request: %s", request)
+		}
+	}
+
+	// the young entry and the one never read back are all that is left pending
+	pending, err := journal.Pending()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(pending) != 2 || pending[0].Subnet != "10.0.0.96" || pending[1].Subnet != "10.0.0.160" || pending[1].RecordId != 4715 {
+		t.Fatalf("expected 10.0.0.96 and 10.0.0.160 to be pending, got: %v", pending)
+	}
+}
@@ -0,0 +1,115 @@
+package miraclient
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// *************************************************
+// CREATE NAMING STRUCTS FOR: THE NAMING CONVENTIONS
+// *************************************************
+
+// the naming conventions of the subnets assigned by this provider, any part can be left out
+type NamingConvention struct {
+	SubnetNameTemplate *template.Template // renders a subnet name from the naming inputs of a resource
+	SubnetNamePattern  *regexp.Regexp     // every subnet name must match this
+	CommentTemplate    *template.Template // renders a comment from the naming inputs and the subnet name
+}
+
+// ==========================================================================
+// FUNC: NewNamingConvention [PARSE THE TEMPLATES AND PATTERN, RETURN ERRORS]
+// ==========================================================================
+
+// Parse the naming conventions of the provider configuration, empty strings leave that part out.
+// A template that uses an input the resource does not give fails, instead of rendering "<no value>"
+func NewNamingConvention(subnetNameTemplate string, subnetNamePattern string, commentTemplate string) (*NamingConvention, error) {
+	var naming NamingConvention
+	var err error
+
+	if subnetNameTemplate != "" {
+		naming.SubnetNameTemplate, err = template.New("subnet_name_template").Option("missingkey=error").Parse(subnetNameTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("Error: could not parse the subnet_name_template: %s", err)
+		}
+	}
+
+	if subnetNamePattern != "" {
+		// the whole name must match, not just a part of it
+		naming.SubnetNamePattern, err = regexp.Compile("^(?:" + subnetNamePattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("Error: could not parse the subnet_name_pattern: %s", err)
+		}
+	}
+
+	if commentTemplate != "" {
+		naming.CommentTemplate, err = template.New("comment_template").Option("missingkey=error").Parse(commentTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("Error: could not parse the comment_template: %s", err)
+		}
+	}
+
+	return &naming, nil
+}
+
+// =============================================================================
+// METHOD: RenderSubnetName [RETURN THE SUBNET NAME FOR THE INPUTS, OR AN ERROR]
+// =============================================================================
+
+// a nil naming convention, or one without a template, renders no name
+func (n *NamingConvention) RenderSubnetName(inputs map[string]string) (string, error) {
+	if n == nil || n.SubnetNameTemplate == nil {
+		return "", nil
+	}
+	return renderNamingTemplate(n.SubnetNameTemplate, inputs)
+}
+
+// ===============================================================================
+// METHOD: RenderComment [RETURN THE COMMENT FOR THE INPUTS AND NAME, OR AN ERROR]
+// ===============================================================================
+
+// the subnet name is given to the template as the input subnet_name, so the comment can mirror it
+func (n *NamingConvention) RenderComment(inputs map[string]string, subnetName string) (string, error) {
+	if n == nil || n.CommentTemplate == nil {
+		return "", nil
+	}
+
+	withName := map[string]string{"subnet_name": subnetName}
+	for key, value := range inputs {
+		withName[key] = value
+	}
+	return renderNamingTemplate(n.CommentTemplate, withName)
+}
+
+// ======================================================================
+// METHOD: CheckSubnetName [RETURN AN ERROR IF A NAME BREAKS THE PATTERN]
+// ======================================================================
+
+func (n *NamingConvention) CheckSubnetName(subnetName string) error {
+	if n == nil || n.SubnetNamePattern == nil {
+		return nil
+	}
+	if !n.SubnetNamePattern.MatchString(subnetName) {
+		return fmt.Errorf("Error: the subnet name %q does not match the naming convention %s", subnetName, n.SubnetNamePattern)
+	}
+	return nil
+}
+
+// funcs to test if a naming convention, which may be nil, can generate a subnet name or comment
+func (n *NamingConvention) GeneratesSubnetName() bool {
+	return n != nil && n.SubnetNameTemplate != nil
+}
+
+func (n *NamingConvention) GeneratesComment() bool {
+	return n != nil && n.CommentTemplate != nil
+}
+
+// func to render a naming template, the inputs are strings so they are given as a map
+func renderNamingTemplate(namingTemplate *template.Template, inputs map[string]string) (string, error) {
+	var rendered strings.Builder
+	if err := namingTemplate.Execute(&rendered, inputs); err != nil {
+		return "", fmt.Errorf("Error: could not render the %s: %s", namingTemplate.Name(), err)
+	}
+	return rendered.String(), nil
+}
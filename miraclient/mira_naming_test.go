@@ -0,0 +1,41 @@
+package miraclient
+
+import (
+	"testing"
+)
+
+func TestMiraNamingConvention(t *testing.T) {
+	naming, err := NewNamingConvention(
+		"{{.env}}-{{.app}}-{{.region}}-{{.nn}}",
+		"(dev|uat|prd)-[a-z0-9]+-[a-z]+[0-9]-[0-9]{2}",
+		"{{.subnet_name}}",
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	inputs := map[string]string{"env": "prd", "app": "shop", "region": "euw3", "nn": "01"}
+	subnetName, err := naming.RenderSubnetName(inputs)
+	if err != nil || subnetName != "prd-shop-euw3-01" {
+		t.Fatalf("expected prd-shop-euw3-01, got %q (%v)", subnetName, err)
+	}
+	if err := naming.CheckSubnetName(subnetName); err != nil {
+		t.Fatalf("expected the generated name to match the pattern: %s", err)
+	}
+
+	// the comment mirrors the subnet name
+	comment, err := naming.RenderComment(inputs, subnetName)
+	if err != nil || comment != subnetName {
+		t.Fatalf("expected the comment %q, got %q (%v)", subnetName, comment, err)
+	}
+
+	// a missing input fails instead of rendering "<no value>"
+	if _, err := naming.RenderSubnetName(map[string]string{"env": "prd"}); err == nil {
+		t.Fatal("expected an error for a missing input")
+	}
+
+	// the whole name must match the pattern
+	if err := naming.CheckSubnetName("prd-shop-euw3-01-extra"); err == nil {
+		t.Fatal("expected an error for a name that only partly matches")
+	}
+}
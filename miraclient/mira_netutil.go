@@ -0,0 +1,33 @@
+package miraclient
+
+import (
+	"fmt"
+	"net"
+)
+
+// --------------------------------------------------
+// NETMASK HELPERS FOR USE IN THE CLIENT AND PROVIDER
+// --------------------------------------------------
+
+// convert a prefix length (eg: 27) into a dotted ipv4 netmask (eg: 255.255.255.224)
+func PrefixLengthToNetmask(prefixLength int) (string, error) {
+	if prefixLength < 0 || prefixLength > 32 {
+		return "", fmt.Errorf("Error: %d is not a valid ipv4 prefix length", prefixLength)
+	}
+	return net.IP(net.CIDRMask(prefixLength, 32)).String(), nil
+}
+
+// convert a dotted ipv4 netmask (eg: 255.255.255.224) into its prefix length (eg: 27)
+func NetmaskToPrefixLength(netmask string) (int, error) {
+	ip := net.ParseIP(netmask).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("Error: %s is not an ipv4 netmask", netmask)
+	}
+
+	// a mask that is not a contiguous run of ones returns a size of 0, 0
+	ones, bits := net.IPMask(ip).Size()
+	if bits == 0 {
+		return 0, fmt.Errorf("Error: %s is not a contiguous ipv4 netmask", netmask)
+	}
+	return ones, nil
+}
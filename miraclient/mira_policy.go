@@ -0,0 +1,64 @@
+package miraclient
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// *******************************************
+// CREATE POLICY STRUCT FOR: ASSIGNMENT POLICY
+// *******************************************
+
+// the templates and ranges this provider may assign subnets with, so a provider configured
+// with the credentials of one environment can not touch the ranges of another. Ranges are
+// given as a range address, eg: "10.20.0.0", or as a CIDR holding ranges, eg: "10.20.0.0/14"
+type AssignmentPolicy struct {
+	AllowedTemplates []string // empty allows every template
+	AllowedRanges    []string // empty allows every range that is not denied
+	DeniedRanges     []string // checked first, so a denied range is never allowed
+}
+
+// the error returned for an assignment the policy does not allow
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("Error: the provider policy does not allow this assignment: %s", e.Reason)
+}
+
+// ===================================================================================
+// METHOD: CheckAssignment [RETURN A POLICY ERROR IF THE TEMPLATE OR RANGE IS BLOCKED]
+// ===================================================================================
+
+func (p *AssignmentPolicy) CheckAssignment(template string, mirarange string) error {
+
+	if denied := matchPolicyRange(p.DeniedRanges, mirarange); denied != "" {
+		return &PolicyError{Reason: fmt.Sprintf("the range %s is in denied_ranges (%s)", mirarange, denied)}
+	}
+
+	if len(p.AllowedRanges) > 0 && matchPolicyRange(p.AllowedRanges, mirarange) == "" {
+		return &PolicyError{Reason: fmt.Sprintf("the range %s is not in allowed_ranges [%s]", mirarange, strings.Join(p.AllowedRanges, ", "))}
+	}
+
+	if len(p.AllowedTemplates) > 0 && !containsString(p.AllowedTemplates, template) {
+		return &PolicyError{Reason: fmt.Sprintf("the template %q is not in allowed_templates [%s]", template, strings.Join(p.AllowedTemplates, ", "))}
+	}
+
+	return nil
+}
+
+// func to find the policy entry matching a range, a range address matches itself and a CIDR the ranges in it
+func matchPolicyRange(entries []string, mirarange string) string {
+	rangeIP := net.ParseIP(mirarange)
+	for _, entry := range entries {
+		if entry == mirarange {
+			return entry
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil && rangeIP != nil && network.Contains(rangeIP) {
+			return entry
+		}
+	}
+	return ""
+}
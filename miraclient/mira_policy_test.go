@@ -0,0 +1,42 @@
+package miraclient
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMiraAssignmentPolicy(t *testing.T) {
+	policy := AssignmentPolicy{
+		AllowedTemplates: []string{"U25_DEV_GCP"},
+		AllowedRanges:    []string{"10.10.0.0/14", "172.16.0.0"},
+		DeniedRanges:     []string{"10.11.0.0"},
+	}
+
+	cases := []struct {
+		template  string
+		mirarange string
+		allowed   bool
+	}{
+		{"U25_DEV_GCP", "10.10.0.0", true},  // in an allowed CIDR
+		{"U25_DEV_GCP", "172.16.0.0", true}, // an allowed range address
+		{"U25_DEV_GCP", "10.11.0.0", false}, // denied, even though it is in an allowed CIDR
+		{"U25_DEV_GCP", "10.20.0.0", false}, // not allowed
+		{"U25_PRD_GCP", "10.10.0.0", false}, // template not allowed
+	}
+
+	for _, c := range cases {
+		err := policy.CheckAssignment(c.template, c.mirarange)
+		if c.allowed != (err == nil) {
+			t.Fatalf("%s in %s: expected allowed %t, got: %v", c.template, c.mirarange, c.allowed, err)
+		}
+		var policyErr *PolicyError
+		if err != nil && !errors.As(err, &policyErr) {
+			t.Fatalf("%s in %s: expected a policy error, got: %v", c.template, c.mirarange, err)
+		}
+	}
+
+	// an empty policy allows everything
+	if err := (&AssignmentPolicy{}).CheckAssignment("U25_PRD_GCP", "10.20.0.0"); err != nil {
+		t.Fatalf("expected an empty policy to allow everything, got: %s", err)
+	}
+}
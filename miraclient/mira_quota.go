@@ -0,0 +1,104 @@
+package miraclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// *****************************************
+// CREATE QUOTA STRUCT FOR: ASSIGNMENT QUOTA
+// *****************************************
+
+// a cap on the subnets held by the assignments matching every selector that is set, eg: the
+// subnets of one address id, or of one template in one range. Mira does not know the quotas,
+// so the subnets already held are counted with a search before every post
+type AssignmentQuota struct {
+	AddressID    string // empty matches every address id
+	Template     string // empty matches every template
+	RequestRange string // empty matches every range
+	MaxCount     int    // the most subnets held, zero is no limit
+	MaxAddresses int    // the most addresses in those subnets, zero is no limit
+}
+
+// the error returned for an assignment that would take a quota over its limit
+type QuotaError struct {
+	Quota  string
+	Reason string
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("Error: the assignment would exceed the provider quota for %s: %s", e.Quota, e.Reason)
+}
+
+// func to test if an assignment is counted by a quota
+func (q *AssignmentQuota) matches(addressID string, template string, mirarange string) bool {
+	return (q.AddressID == "" || q.AddressID == addressID) &&
+		(q.Template == "" || q.Template == template) &&
+		(q.RequestRange == "" || q.RequestRange == mirarange)
+}
+
+// func to name a quota by its selectors, eg: "address_id=1234567, template=U25_PRD_GCP"
+func (q *AssignmentQuota) String() string {
+	var selectors []string
+	if q.AddressID != "" {
+		selectors = append(selectors, "address_id="+q.AddressID)
+	}
+	if q.Template != "" {
+		selectors = append(selectors, "template="+q.Template)
+	}
+	if q.RequestRange != "" {
+		selectors = append(selectors, "request_range="+q.RequestRange)
+	}
+	return strings.Join(selectors, ", ")
+}
+
+// ======================================================================================
+// METHOD: checkQuotas [COUNT THE SUBNETS HELD, RETURN A QUOTA ERROR IF ONE MORE IS OVER]
+// ======================================================================================
+
+// Check one more subnet with the mask fits every quota matching the assignment. The subnets
+// held are searched with the selectors of each quota, so every quota needs at least one
+func (c *Client) checkQuotas(postInput *MiraSubnetAssignmentPostInput) error {
+
+	newPrefixLength, err := NetmaskToPrefixLength(postInput.RequestMask)
+	if err != nil {
+		return err
+	}
+	newAddresses := 1 << uint(32-newPrefixLength)
+
+	for i := range c.Quotas {
+		quota := &c.Quotas[i]
+		if !quota.matches(postInput.AddressID, postInput.Template, postInput.RequestRange) {
+			continue
+		}
+
+		held, err := c.SearchMiraSubnets(&MiraSubnetSearchQueryInput{
+			AddressID: quota.AddressID,
+			Range:     quota.RequestRange,
+			Template:  quota.Template,
+		})
+		if err != nil {
+			return fmt.Errorf("Error: could not count the subnets held for the quota %s: %s", quota, err)
+		}
+
+		if quota.MaxCount > 0 && len(held)+1 > quota.MaxCount {
+			return &QuotaError{Quota: quota.String(), Reason: fmt.Sprintf("%d subnets are held, and the limit is %d", len(held), quota.MaxCount)}
+		}
+
+		if quota.MaxAddresses > 0 {
+			heldAddresses := 0
+			for _, record := range held {
+				prefixLength, err := NetmaskToPrefixLength(record.IpMask)
+				if err != nil {
+					return fmt.Errorf("Error: could not count the addresses of subnet %s for the quota %s: %s", record.IpAddress, quota, err)
+				}
+				heldAddresses += 1 << uint(32-prefixLength)
+			}
+			if heldAddresses+newAddresses > quota.MaxAddresses {
+				return &QuotaError{Quota: quota.String(), Reason: fmt.Sprintf("%d addresses are held, %d more would go over the limit of %d", heldAddresses, newAddresses, quota.MaxAddresses)}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package miraclient
+
+import (
+	"fmt"
+	"time"
+)
+
+// the first and the longest wait between two refreshes of a waiter
+const waitMinInterval time.Duration = 1 * time.Second
+const waitMaxInterval time.Duration = 10 * time.Second
+
+// how long a waiter polls when the caller did not give a timeout
+const DefaultWaitTimeout time.Duration = 5 * time.Minute
+
+// ****************************************************
+// CREATE WAITER STRUCTS FOR: POLLING ASYNC MIRA STATES
+// ****************************************************
+
+// a waiter in the style of the terraform sdk StateChangeConf, kept in the client so
+// the client does not depend on the sdk. Refresh returns the object, and its state
+type miraStateWaiter struct {
+	Pending []string
+	Target  []string
+	Timeout time.Duration
+	Refresh func() (interface{}, string, error)
+}
+
+// the error returned when the target state was not reached in time
+type WaitTimeoutError struct {
+	LastState string
+	Timeout   time.Duration
+}
+
+func (e *WaitTimeoutError) Error() string {
+	return fmt.Sprintf("Error: timeout while waiting for mira to finish (last state: %q, timeout: %s)", e.LastState, e.Timeout)
+}
+
+// ===============================================================================
+// METHOD: WaitForState [REFRESH UNTIL A TARGET STATE, RETURN THE OBJECT OR ERROR]
+// ===============================================================================
+
+func (w *miraStateWaiter) WaitForState() (interface{}, error) {
+
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWaitTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	interval := waitMinInterval
+
+	for {
+		object, state, err := w.Refresh()
+		if err != nil {
+			return nil, err
+		}
+		if containsString(w.Target, state) {
+			return object, nil
+		}
+		if !containsString(w.Pending, state) {
+			return nil, fmt.Errorf("Error: mira returned the unexpected state %q, expected one of: %v", state, w.Target)
+		}
+
+		// give up when the next refresh would be after the deadline
+		if time.Now().Add(interval).After(deadline) {
+			return nil, &WaitTimeoutError{LastState: state, Timeout: timeout}
+		}
+		time.Sleep(interval)
+
+		// back off, so a slow job is not polled every second
+		interval *= 2
+		if interval > waitMaxInterval {
+			interval = waitMaxInterval
+		}
+	}
+}
+
+// func to test if a list of strings holds a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}